@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"log"
-	"time"
 
 	"github.com/google/gousb"
 )
@@ -49,21 +48,16 @@ func main() {
 		log.Fatalf("%s.InEndpoint(1): %v", intf, err)
 	}
 
-	// Write data to the USB device.
-	numBytes, err := epOut.Write([]byte("*IDN?"))
-	if numBytes != 5 {
-		log.Fatalf("%s.Write([5]): only %d bytes written, returned error is %v", epOut, numBytes, err)
+	tmc := &usbtmc{out: epOut, in: epIn}
+	if err := tmc.Write([]byte("*IDN?")); err != nil {
+		log.Fatal(err)
 	}
-	fmt.Println("5 bytes successfully sent to the endpoint")
+	fmt.Println("*IDN? sent")
 
-	// Buffer large enough for 10 USB packets from endpoint 6.
-	buf := make([]byte, 10*epIn.Desc.MaxPacketSize)
-	time.Sleep(1 * time.Second)
-	// readBytes might be smaller than the buffer size. readBytes might be greater than zero even if err is not nil.
-	readBytes, err := epIn.Read(buf)
+	resp, err := tmc.Read(1024)
 	if err != nil {
-		fmt.Println("Read returned an error:", err)
+		log.Fatal(err)
 	}
-	fmt.Printf("Read %d bytes\n", readBytes)
-	fmt.Println(string(buf))
+	fmt.Printf("Read %d bytes\n", len(resp))
+	fmt.Println(string(resp))
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/google/gousb"
+)
+
+// USBTMC bulk message IDs and attributes, see the USBTMC spec section 3.2
+const (
+	msgDevDepOut       = 1 // DEV_DEP_MSG_OUT, a command to the device
+	msgRequestDevDepIn = 2 // REQUEST_DEV_DEP_MSG_IN, ask for a response
+	msgDevDepIn        = 2 // DEV_DEP_MSG_IN, a response from the device
+
+	attrEOM = 0x01 // bmTransferAttributes: last transfer of the message
+
+	headerLen = 12
+)
+
+// usbtmc frames messages on a pair of USBTMC bulk endpoints
+type usbtmc struct {
+	out *gousb.OutEndpoint
+	in  *gousb.InEndpoint
+	tag byte
+}
+
+// header builds the 12 byte bulk header for a transfer, bumping the tag,
+// which must be 1-255 and differ from the last one used
+func (u *usbtmc) header(msgID byte, size uint32, attrs byte) []byte {
+	u.tag++
+	if u.tag == 0 {
+		u.tag = 1
+	}
+	h := make([]byte, headerLen)
+	h[0] = msgID
+	h[1] = u.tag
+	h[2] = ^u.tag
+	binary.LittleEndian.PutUint32(h[4:], size)
+	h[8] = attrs
+	return h
+}
+
+// Write sends msg as a single DEV_DEP_MSG_OUT transfer with EOM set
+func (u *usbtmc) Write(msg []byte) error {
+	b := append(u.header(msgDevDepOut, uint32(len(msg)), attrEOM), msg...)
+	for len(b)%4 != 0 {
+		b = append(b, 0) // transfers are padded to a multiple of 4 bytes
+	}
+	if _, err := u.out.Write(b); err != nil {
+		return fmt.Errorf("USBTMC write failed: %v", err)
+	}
+	return nil
+}
+
+// Read returns a whole response. The device may split it over several
+// DEV_DEP_MSG_IN transfers, each of which can span several USB packets, so
+// each transfer is read until its TransferSize has arrived, and transfers are
+// requested until one has the EOM bit set.
+func (u *usbtmc) Read(max uint32) ([]byte, error) {
+	var msg []byte
+	for {
+		req := u.header(msgRequestDevDepIn, max-uint32(len(msg)), 0)
+		tag := u.tag
+		if _, err := u.out.Write(req); err != nil {
+			return nil, fmt.Errorf("USBTMC read request failed: %v", err)
+		}
+
+		buf := make([]byte, u.in.Desc.MaxPacketSize*64)
+		n, err := u.in.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("USBTMC read failed: %v", err)
+		}
+		if n < headerLen {
+			return nil, errors.New("USBTMC transfer shorter than its header")
+		}
+		h := buf[:headerLen]
+		if h[0] != msgDevDepIn || h[1] != tag || h[2] != ^tag {
+			return nil, fmt.Errorf("unexpected USBTMC header % x", h)
+		}
+		size := int(binary.LittleEndian.Uint32(h[4:]))
+		eom := h[8]&attrEOM != 0
+
+		data := append([]byte(nil), buf[headerLen:n]...)
+		for len(data) < size {
+			n, err := u.in.Read(buf)
+			if err != nil {
+				return nil, fmt.Errorf("USBTMC read failed after %d of %d bytes: %v", len(data), size, err)
+			}
+			data = append(data, buf[:n]...)
+		}
+		// anything past size is alignment padding
+		msg = append(msg, data[:size]...)
+
+		if eom || uint32(len(msg)) >= max {
+			return msg, nil
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// AcquireAveraged takes n single shot captures of source, an analog channel,
+// with cfg and returns the point by point average of their values (in the
+// channel's unit, see Volts). Waveform data must be in BYTE format.
+func (r *Rigol) AcquireAveraged(cfg TriggerConfig, source Source, n int) ([]float64, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid capture count %d", n)
+	}
+	kind, _, err := source.parse()
+	if err != nil {
+		return nil, err
+	}
+	if kind != "CHAN" {
+		return nil, fmt.Errorf("can only average analog channels, not %s", source)
+	}
+	if r.waveformFormat() != FormatByte {
+		return nil, fmt.Errorf("waveform format must be %s to convert to volts", FormatByte)
+	}
+	var sum []float64
+	for i := 0; i < n; i++ {
+		if err := r.Arm(cfg); err != nil {
+			return nil, err
+		}
+		if _, err := r.WaitForCapture(); err != nil {
+			return nil, err
+		}
+		data, p, err := r.acquire(source)
+		if err != nil {
+			return nil, err
+		}
+		v := p.Volts(data)
+		if sum == nil {
+			sum = v
+			continue
+		}
+		if len(v) != len(sum) {
+			return nil, fmt.Errorf("capture %d has %d points, expected %d", i+1, len(v), len(sum))
+		}
+		for j := range v {
+			sum[j] += v[j]
+		}
+	}
+	for j := range sum {
+		sum[j] /= float64(n)
+	}
+	return sum, nil
+}
+
+// Acquire stops the scope and reads every point of source, fetching the
+// preamble first so each read is sized from the actual point count and sample
+// width rather than a guess. A scope that was running is set running again
+// afterwards, see pause.
+func (r *Rigol) Acquire(source Source) ([]byte, *Preamble, error) {
+	var data []byte
+	var p *Preamble
+	err := r.whilePaused(func() (err error) {
+		data, p, err = r.acquire(source)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, p, nil
+}
+
+// whilePaused runs fn with the scope stopped, then puts it back how it was,
+// whether or not fn succeeded. See pause.
+func (r *Rigol) whilePaused(fn func() error) error {
+	resume, err := r.pause()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	if rerr := resume(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// pause stops the scope for a RAW read, returning a function that puts it back
+// how it was: running again in single shot if that was the sweep mode, or
+// continuously, or left alone if it was already stopped
+func (r *Rigol) pause() (func() error, error) {
+	state, err := r.triggerStatus()
+	if err != nil {
+		return nil, err
+	}
+	if state == "STOP" {
+		return func() error { return nil }, nil
+	}
+	sweep, err := r.Query(":TRIG:SWE?")
+	if err != nil {
+		return nil, err
+	}
+	if err := r.stop(); err != nil {
+		return nil, err
+	}
+	restart := ":RUN"
+	if sweep == "SING" {
+		restart = ":SING"
+	}
+	return func() error {
+		r.runState = ""
+		r.forcedStop = false
+		return r.Write(restart)
+	}, nil
+}
+
+// acquire is Acquire for a scope that's already stopped
+func (r *Rigol) acquire(source Source) ([]byte, *Preamble, error) {
+	if err := r.selectSource(source); err != nil {
+		return nil, nil, err
+	}
+	p, err := r.sourcePreamble(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := r.fetchRange(context.Background(), 1, p.Points)
+	if err != nil {
+		return nil, nil, err
+	}
+	if expected := p.Points * int64(r.BytesPerSample()); int64(len(data)) != expected {
+		return nil, nil, fmt.Errorf("expected %d bytes of waveform data, got %d", expected, len(data))
+	}
+	return data, p, nil
+}
+
+// MemoryDepths returns the memory depths the scope accepts with the analog
+// channels and LA pods currently turned on, and the memory the model has,
+// shallowest first
+func (r *Rigol) MemoryDepths() ([]int64, error) {
+	caps, err := r.Capabilities()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := r.channelGroups(caps)
+	if err != nil {
+		return nil, err
+	}
+	return memoryDepths(caps.MaxMemory, groups), nil
+}
+
+// MaxMemoryDepth returns the deepest of MemoryDepths
+func (r *Rigol) MaxMemoryDepth() (int64, error) {
+	depths, err := r.MemoryDepths()
+	if err != nil {
+		return 0, err
+	}
+	return depths[len(depths)-1], nil
+}
+
+// channelGroups counts the analog channels and LA pods turned on, which share
+// the memory between them
+func (r *Rigol) channelGroups(caps Capabilities) (int, error) {
+	groups := 0
+	for ch := 1; ch <= caps.MaxChannels; ch++ {
+		disp, err := r.Query(fmt.Sprintf(":CHAN%d:DISP?", ch))
+		if err != nil {
+			return 0, err
+		}
+		if disp == "1" {
+			groups++
+		}
+	}
+	if !caps.HasLA {
+		return groups, nil
+	}
+	la, err := r.Query(":LA:STAT?")
+	if err != nil {
+		return 0, err
+	}
+	if la == "1" {
+		for pod := 1; pod <= 2; pod++ {
+			disp, err := r.Query(fmt.Sprintf(":LA:POD%d:DISP?", pod))
+			if err != nil {
+				return 0, err
+			}
+			if disp == "1" {
+				groups++
+			}
+		}
+	}
+	return groups, nil
+}
+
+// SetMemoryDepth sets :ACQ:MDEP, first checking it's one of the depths the
+// scope accepts with the channels currently turned on
+func (r *Rigol) SetMemoryDepth(depth int64) error {
+	depths, err := r.MemoryDepths()
+	if err != nil {
+		return err
+	}
+	if err := checkMemoryDepth(depth, depths, "the channels turned on"); err != nil {
+		return err
+	}
+	return r.Write(cmd(":ACQ:MDEP", depth))
+}
+
+// SetAverages sets the number of captures averaged in AVER acquisition mode, a
+// power of 2 from 2 to 1024. It has no effect in the other modes.
+func (r *Rigol) SetAverages(n int) error {
+	if n < 2 || n > 1024 || n&(n-1) != 0 {
+		return fmt.Errorf("invalid average count %d, expected a power of 2 from 2 to 1024", n)
+	}
+	return r.Write(cmd(":ACQ:AVER", n))
+}
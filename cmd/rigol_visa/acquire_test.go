@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestAcquireAveragedRejects(t *testing.T) {
+	tests := []struct {
+		source Source
+		format WaveformFormat
+	}{
+		{SourceMath, FormatByte},
+		{SourceDigital(0), FormatByte},
+		{SourceCH(1), FormatWord},
+	}
+	for _, tt := range tests {
+		f := &fakeScope{handle: replies(nil)}
+		r := &Rigol{Transport: f, format: tt.format}
+		if _, err := r.AcquireAveraged(DefaultTriggerConfig(), tt.source, 4); err == nil {
+			t.Errorf("%s in %s format: expected an error", tt.source, tt.format)
+		}
+		if len(f.written) != 0 {
+			t.Errorf("%s in %s format: sent %q before rejecting it", tt.source, tt.format, f.written)
+		}
+	}
+}
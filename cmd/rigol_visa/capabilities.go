@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupported is returned for features the connected model doesn't have
+var ErrUnsupported = errors.New("not supported by this model")
+
+// Capabilities describes what the connected model can do, as far as can be
+// told from its model number
+type Capabilities struct {
+	Model       string // e.g. MSO1104Z-S
+	Serial      string
+	Firmware    string
+	HasLA       bool  // MSO models have the 16 channel logic analyser
+	HasAWG      bool  // -S models have the 2 channel signal generator
+	HasExtTrig  bool  // 2 channel models have an EXT trigger input
+	MaxChannels int   // analog channels
+	MaxMemory   int64 // points, with one channel on, see WithDeepMemory
+}
+
+// Capabilities identifies the scope with *IDN?. The answer is kept for the
+// rest of the connection, so only the first call talks to the scope.
+func (r *Rigol) Capabilities() (Capabilities, error) {
+	if r.caps != nil {
+		return *r.caps, nil
+	}
+	idn, err := r.Query("*IDN?")
+	if err != nil {
+		return Capabilities{}, err
+	}
+	c, err := parseCapabilities(idn)
+	if err != nil {
+		return Capabilities{}, err
+	}
+	if r.deepMemory {
+		c.MaxMemory = 24000000
+	}
+	r.caps = &c
+	return c, nil
+}
+
+// parseCapabilities parses an *IDN? response, e.g.
+// RIGOL TECHNOLOGIES,MSO1104Z,DS1ZC000000000,00.04.04.SP3. The MSO, Plus and
+// -E models have 24M points of memory as standard, the others 12M unless the
+// memory option is installed, which *IDN? doesn't show.
+func parseCapabilities(idn string) (Capabilities, error) {
+	fields := strings.Split(idn, ",")
+	if len(fields) != 4 {
+		return Capabilities{}, fmt.Errorf("unexpected *IDN? response %q", idn)
+	}
+	c := Capabilities{
+		Model:     strings.TrimSpace(fields[1]),
+		Serial:    strings.TrimSpace(fields[2]),
+		Firmware:  strings.TrimSpace(fields[3]),
+		MaxMemory: 12000000,
+	}
+	model := strings.ToUpper(c.Model)
+	c.HasLA = strings.HasPrefix(model, "MSO")
+	c.HasAWG = strings.Contains(model, "-S")
+	if c.HasLA || strings.Contains(model, "PLUS") || strings.HasSuffix(model, "-E") {
+		c.MaxMemory = 24000000
+	}
+
+	// DS<series><bandwidth, 2 digits><channels>Z, e.g. DS1054Z or DS1202Z-E
+	digits := strings.TrimLeft(model, "DMSO")
+	if len(digits) < 4 || digits[3] < '1' || digits[3] > '4' {
+		return Capabilities{}, fmt.Errorf("unrecognised model %q", c.Model)
+	}
+	c.MaxChannels = int(digits[3] - '0')
+	c.HasExtTrig = c.MaxChannels == 2
+	return c, nil
+}
+
+// RequireModel returns an error unless the model reported by *IDN? contains
+// substr, e.g. "DS1054Z" or just "MSO", ignoring case
+func (r *Rigol) RequireModel(substr string) error {
+	caps, err := r.Capabilities()
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(strings.ToUpper(caps.Model), strings.ToUpper(substr)) {
+		return fmt.Errorf("connected to a %s, expected a %s", caps.Model, substr)
+	}
+	return nil
+}
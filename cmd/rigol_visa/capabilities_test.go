@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	tests := []struct {
+		idn  string
+		want Capabilities
+	}{
+		{
+			"RIGOL TECHNOLOGIES,DS1054Z,DS1ZA000000000,00.04.04.SP3",
+			Capabilities{Model: "DS1054Z", Serial: "DS1ZA000000000", Firmware: "00.04.04.SP3", MaxChannels: 4, MaxMemory: 12000000},
+		},
+		{
+			"RIGOL TECHNOLOGIES,MSO1104Z-S,DS1ZC000000000,00.04.04.SP3",
+			Capabilities{Model: "MSO1104Z-S", Serial: "DS1ZC000000000", Firmware: "00.04.04.SP3", HasLA: true, HasAWG: true, MaxChannels: 4, MaxMemory: 24000000},
+		},
+		{
+			"RIGOL TECHNOLOGIES,DS1202Z-E,DS1ZE000000000,00.06.02",
+			Capabilities{Model: "DS1202Z-E", Serial: "DS1ZE000000000", Firmware: "00.06.02", HasExtTrig: true, MaxChannels: 2, MaxMemory: 24000000},
+		},
+		{
+			"RIGOL TECHNOLOGIES,DS1104Z Plus,DS1ZD000000000,00.04.04.SP4",
+			Capabilities{Model: "DS1104Z Plus", Serial: "DS1ZD000000000", Firmware: "00.04.04.SP4", MaxChannels: 4, MaxMemory: 24000000},
+		},
+	}
+	for _, tt := range tests {
+		got, err := parseCapabilities(tt.idn)
+		if err != nil {
+			t.Errorf("%s: %v", tt.idn, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.idn, got, tt.want)
+		}
+	}
+	if _, err := parseCapabilities("RIGOL TECHNOLOGIES,DS1054Z"); err == nil {
+		t.Error("expected an error for a short *IDN? response")
+	}
+}
+
+func TestCapabilitiesCached(t *testing.T) {
+	f := &fakeScope{handle: replies(map[string][]string{
+		"*IDN?": {"RIGOL TECHNOLOGIES,DS1054Z,DS1ZA000000000,00.04.04.SP3"},
+	})}
+	r := &Rigol{Transport: f}
+	r.applyOptions([]Option{WithDeepMemory()})
+	for i := 0; i < 3; i++ {
+		caps, err := r.Capabilities()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if caps.MaxMemory != 24000000 {
+			t.Errorf("got MaxMemory %d with WithDeepMemory, want 24000000", caps.MaxMemory)
+		}
+	}
+	if len(f.written) != 1 {
+		t.Errorf("sent %q, want a single *IDN?", f.written)
+	}
+}
+
+func TestCheckSource(t *testing.T) {
+	ds, _ := parseCapabilities("RIGOL TECHNOLOGIES,DS1054Z,DS1ZA000000000,00.04.04.SP3")
+	twoCh, _ := parseCapabilities("RIGOL TECHNOLOGIES,DS1202Z-E,DS1ZE000000000,00.06.02")
+	mso, _ := parseCapabilities("RIGOL TECHNOLOGIES,MSO1104Z-S,DS1ZC000000000,00.04.04.SP3")
+	tests := []struct {
+		caps        Capabilities
+		source      Source
+		unsupported bool
+	}{
+		{ds, SourceCH(4), false},
+		{ds, SourceDigital(0), true},
+		{twoCh, SourceCH(2), false},
+		{twoCh, SourceCH(3), true},
+		{mso, SourceDigital(15), false},
+		{mso, SourceMath, false},
+	}
+	for _, tt := range tests {
+		err := tt.caps.CheckSource(tt.source)
+		if errors.Is(err, ErrUnsupported) != tt.unsupported || (err != nil && !tt.unsupported) {
+			t.Errorf("%s on %s: got %v", tt.source, tt.caps.Model, err)
+		}
+	}
+	if err := ds.CheckSource(SourceCH(5)); err == nil || errors.Is(err, ErrUnsupported) {
+		t.Errorf("CHAN5: got %v, want an invalid source error", err)
+	}
+}
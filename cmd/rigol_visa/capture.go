@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Capture is waveform data along with what's needed to interpret it later
+type Capture struct {
+	Source   Source // where the data came from, e.g. D0 or CHAN1
+	Preamble *Preamble
+	Data     []byte
+}
+
+// Save writes the source, the preamble and its unit and invert setting (which
+// :WAV:PRE? doesn't include) as lines of text, followed by the raw data. The
+// preamble is needed to load the capture, so it can't be nil.
+func (c *Capture) Save(w io.Writer) error {
+	if c.Preamble == nil {
+		return errors.New("capture has no preamble")
+	}
+	p := c.Preamble
+	if _, err := fmt.Fprintf(w, "%s\n%s\n%s,%t\n", c.Source, p, p.Unit, p.Inverted); err != nil {
+		return err
+	}
+	_, err := w.Write(c.Data)
+	return err
+}
+
+// LoadCapture reads back a capture written by Save
+func LoadCapture(r io.Reader) (*Capture, error) {
+	br := bufio.NewReader(r)
+	source, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("reading capture source: %v", err)
+	}
+	preamble, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("reading capture preamble: %v", err)
+	}
+	p, err := ParsePreamble(preamble)
+	if err != nil {
+		return nil, err
+	}
+	display, err := br.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("reading capture unit: %v", err)
+	}
+	unit, inverted, ok := strings.Cut(strings.TrimSpace(display), ",")
+	if !ok {
+		return nil, fmt.Errorf("malformed capture unit line %q", display)
+	}
+	p.Unit = Unit(unit)
+	if p.Inverted, err = strconv.ParseBool(inverted); err != nil {
+		return nil, fmt.Errorf("malformed capture unit line %q", display)
+	}
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	return &Capture{Source: Source(strings.TrimSpace(source)), Preamble: p, Data: data}, nil
+}
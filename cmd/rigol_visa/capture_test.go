@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCaptureRoundTrip(t *testing.T) {
+	c := &Capture{
+		Source:   SourceDigital(0),
+		Preamble: &Preamble{Points: 4, Count: 1, Xincrement: 1e-6, Yincrement: 1, Yref: 127},
+		Data:     []byte{0, '\n', 0xff, 3},
+	}
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadCapture(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("got %+v, want %+v", got, c)
+	}
+}
+
+func TestCaptureRoundTripUnitInverted(t *testing.T) {
+	c := &Capture{
+		Source:   SourceCH(2),
+		Preamble: &Preamble{Points: 2, Count: 1, Xincrement: 1e-6, Yincrement: 0.1, Yref: 127, Unit: UnitAmp, Inverted: true},
+		Data:     []byte{100, 200},
+	}
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadCapture(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("got %+v, want %+v", got.Preamble, c.Preamble)
+	}
+	if v := got.Preamble.Volts([]byte{200}); v[0] >= 0 {
+		t.Errorf("loaded capture gives %g, want it inverted", v[0])
+	}
+}
+
+func TestCaptureSaveNilPreamble(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (&Capture{Source: SourceCH(1), Data: []byte{1}}).Save(&buf); err == nil {
+		t.Fatal("expected an error saving a capture with no preamble")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("wrote %q before failing", buf.Bytes())
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Coupling is the input coupling of an analog channel
+type Coupling string
+
+const (
+	CouplingAC  Coupling = "AC"  // blocks the DC component
+	CouplingDC  Coupling = "DC"  // passes both AC and DC
+	CouplingGND Coupling = "GND" // disconnects the input
+)
+
+func checkChannel(ch int) error {
+	if ch < 1 || ch > 4 {
+		return fmt.Errorf("invalid channel %d, expected 1-4", ch)
+	}
+	return nil
+}
+
+// SetChannelCoupling sets the input coupling of analog channel ch (1-4)
+func (r *Rigol) SetChannelCoupling(ch int, c Coupling) error {
+	if err := checkChannel(ch); err != nil {
+		return err
+	}
+	switch c {
+	case CouplingAC, CouplingDC, CouplingGND:
+	default:
+		return fmt.Errorf("invalid coupling %q", c)
+	}
+	return r.Write(cmd(fmt.Sprintf(":CHAN%d:COUP", ch), c))
+}
+
+// SetBandwidthLimit turns the 20MHz bandwidth limit on analog channel ch on or off
+func (r *Rigol) SetBandwidthLimit(ch int, on bool) error {
+	if err := checkChannel(ch); err != nil {
+		return err
+	}
+	bwl := "OFF"
+	if on {
+		bwl = "20M"
+	}
+	return r.Write(cmd(fmt.Sprintf(":CHAN%d:BWL", ch), bwl))
+}
+
+// SetInputImpedance sets analog channel ch to 50 or 1000000 ohms. Models without
+// a 50Ω input ignore the command, which is detected by reading it back.
+func (r *Rigol) SetInputImpedance(ch int, ohms int) error {
+	if err := checkChannel(ch); err != nil {
+		return err
+	}
+	var imp string
+	switch ohms {
+	case 50:
+		imp = "FIFT"
+	case 1000000:
+		imp = "OMEG"
+	default:
+		return fmt.Errorf("invalid input impedance %d, expected 50 or 1000000", ohms)
+	}
+	if err := r.Write(cmd(fmt.Sprintf(":CHAN%d:IMP", ch), imp)); err != nil {
+		return err
+	}
+	got, err := r.Query(fmt.Sprintf(":CHAN%d:IMP?", ch))
+	if err != nil {
+		return err
+	}
+	if got != imp {
+		return fmt.Errorf("%dΩ input impedance not supported on this model", ohms)
+	}
+	return nil
+}
+
+// ProbeRatio returns the probe attenuation set on analog channel ch, e.g. 10
+// for a 10x probe. The preamble's Yincrement already includes it, so this is
+// for reporting or checking the setup rather than for scaling data by hand.
+func (r *Rigol) ProbeRatio(ch int) (float64, error) {
+	if err := checkChannel(ch); err != nil {
+		return 0, err
+	}
+	return r.QueryFloat(fmt.Sprintf(":CHAN%d:PROB?", ch))
+}
+
+// SetChannelScale sets analog channel ch to v volts per division, then reads
+// it back until it matches, as a query straight after the change can still
+// return the old value. Without vernier the scope rounds to the 1-2-5
+// sequence, so a v off it never reads back and times out after a second.
+func (r *Rigol) SetChannelScale(ch int, v float64) error {
+	if err := checkChannel(ch); err != nil {
+		return err
+	}
+	if v <= 0 {
+		return fmt.Errorf("invalid channel scale %g", v)
+	}
+	if err := r.Write(cmd(fmt.Sprintf(":CHAN%d:SCAL", ch), v)); err != nil {
+		return err
+	}
+	var got float64
+	for i := 0; i < 20; i++ {
+		var err error
+		got, err = r.QueryFloat(fmt.Sprintf(":CHAN%d:SCAL?", ch))
+		if err != nil {
+			return err
+		}
+		if math.Abs(got-v) <= 1e-6*v {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return fmt.Errorf("CH%d scale reads back as %g, expected %g", ch, got, v)
+}
+
+// SetChannelOffset sets the vertical offset of analog channel ch. The scope
+// clamps an out of range offset without complaint, so it's checked here
+// against the range for the current scale and probe: ±2v below 500mV/div and
+// ±100v from there up, both at the probe tip for a 1x probe.
+func (r *Rigol) SetChannelOffset(ch int, volts float64) error {
+	if err := checkChannel(ch); err != nil {
+		return err
+	}
+	probe, err := r.ProbeRatio(ch)
+	if err != nil {
+		return err
+	}
+	scale, err := r.QueryFloat(fmt.Sprintf(":CHAN%d:SCAL?", ch))
+	if err != nil {
+		return err
+	}
+	limit := 100 * probe
+	if scale/probe < 0.5 {
+		limit = 2 * probe
+	}
+	if math.Abs(volts) > limit {
+		return fmt.Errorf("CH%d offset %gv out of range ±%gv at %gv/div", ch, volts, limit, scale)
+	}
+	return r.Write(cmd(fmt.Sprintf(":CHAN%d:OFFS", ch), volts))
+}
+
+// Unit is what an analog channel's probe measures, which sets the unit the
+// scope (and Volts) reports values in
+type Unit string
+
+const (
+	UnitVolt    Unit = "VOLT"
+	UnitAmp     Unit = "AMP"
+	UnitWatt    Unit = "WATT"
+	UnitUnknown Unit = "UNKN"
+
+	// UnitDecibel is only used for math FFT results, channels can't be set to it
+	UnitDecibel Unit = "DB"
+)
+
+// Symbol is the unit's abbreviation for labels, V if u is empty
+func (u Unit) Symbol() string {
+	switch u {
+	case UnitAmp:
+		return "A"
+	case UnitWatt:
+		return "W"
+	case UnitUnknown:
+		return "U"
+	case UnitDecibel:
+		return "dB"
+	}
+	return "V"
+}
+
+// SetChannelUnit sets the unit of analog channel ch, e.g. AMP for a current
+// probe
+func (r *Rigol) SetChannelUnit(ch int, unit Unit) error {
+	if err := checkChannel(ch); err != nil {
+		return err
+	}
+	switch unit {
+	case UnitVolt, UnitAmp, UnitWatt, UnitUnknown:
+	default:
+		return fmt.Errorf("invalid unit %q", unit)
+	}
+	return r.Write(cmd(fmt.Sprintf(":CHAN%d:UNIT", ch), unit))
+}
+
+// ChannelUnit returns the unit of analog channel ch
+func (r *Rigol) ChannelUnit(ch int) (Unit, error) {
+	if err := checkChannel(ch); err != nil {
+		return "", err
+	}
+	u, err := r.Query(fmt.Sprintf(":CHAN%d:UNIT?", ch))
+	return Unit(u), err
+}
+
+// ChannelInverted reports whether analog channel ch is displayed inverted
+func (r *Rigol) ChannelInverted(ch int) (bool, error) {
+	if err := checkChannel(ch); err != nil {
+		return false, err
+	}
+	inv, err := r.Query(fmt.Sprintf(":CHAN%d:INV?", ch))
+	return inv == "1", err
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Decoder turns logic analyser data into protocol events. Implementations
+// carry their own settings, e.g. which channels and what bit rate.
+type Decoder interface {
+	Decode(capture *LogicCapture) ([]DecodeEvent, error)
+}
+
+// DecoderFunc lets an ordinary function be used as a Decoder
+type DecoderFunc func(capture *LogicCapture) ([]DecodeEvent, error)
+
+func (f DecoderFunc) Decode(capture *LogicCapture) ([]DecodeEvent, error) {
+	return f(capture)
+}
+
+// decoders holds the registered decoders. The built-in ones are there from the
+// start with common settings: "uart" for 9600 baud on D0 and "manchester" for
+// IEEE convention on D0 at 1ms per bit. Register a UARTDecoder or
+// ManchesterDecoder under another name for other settings.
+var decoders = struct {
+	mu sync.RWMutex
+	m  map[string]Decoder
+}{m: map[string]Decoder{
+	"uart":       UARTDecoder{Pin: 0, Baud: 9600},
+	"manchester": ManchesterDecoder{Pin: 0, BitPeriod: time.Millisecond, Convention: ManchesterIEEE},
+}}
+
+// RegisterDecoder makes d available to Decode under name. Registering the
+// same name twice is an error rather than silently replacing a decoder.
+func RegisterDecoder(name string, d Decoder) error {
+	decoders.mu.Lock()
+	defer decoders.mu.Unlock()
+	if _, ok := decoders.m[name]; ok {
+		return fmt.Errorf("decoder %q already registered", name)
+	}
+	decoders.m[name] = d
+	return nil
+}
+
+// Decoders lists the registered decoder names in order
+func Decoders() []string {
+	decoders.mu.RLock()
+	defer decoders.mu.RUnlock()
+	names := make([]string, 0, len(decoders.m))
+	for name := range decoders.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Decode runs the decoder registered as name on capture
+func Decode(name string, capture *LogicCapture) ([]DecodeEvent, error) {
+	decoders.mu.RLock()
+	d, ok := decoders.m[name]
+	decoders.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no decoder registered as %q", name)
+	}
+	return d.Decode(capture)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// uart encodes bytes as 8N1 on pin 0, idle high, with spb samples per bit
+func uart(bytes []byte, spb int) []byte {
+	data := []byte{1, 1, 1, 1}
+	for _, b := range bytes {
+		bits := []byte{0}
+		for i := 0; i < 8; i++ {
+			bits = append(bits, (b>>i)&1)
+		}
+		bits = append(bits, 1)
+		for _, bit := range bits {
+			for i := 0; i < spb; i++ {
+				data = append(data, bit)
+			}
+		}
+	}
+	return append(data, 1, 1, 1, 1)
+}
+
+func TestBuiltinDecoders(t *testing.T) {
+	// a saved capture at 10 samples per bit of 9600 baud, starting 1ms before
+	// the trigger
+	c := &Capture{
+		Preamble: &Preamble{Xincrement: 1.0 / 96000, Xorigin: -1e-3},
+		Data:     uart([]byte("Hi"), 10),
+	}
+	events, err := Decode("uart", ReplayCapture(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DecodeEvent{
+		{Time: -1e-3 + 4.0/96000, Type: "UART", Value: "0x48"},
+		{Time: -1e-3 + 104.0/96000, Type: "UART", Value: "0x69"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %v, want %v", events, want)
+	}
+	for i := range want {
+		if events[i].Value != want[i].Value || events[i].Type != want[i].Type || math.Abs(events[i].Time-want[i].Time) > 1e-12 {
+			t.Errorf("event %d: got %+v, want %+v", i, events[i], want[i])
+		}
+	}
+
+	// 8 samples per bit at 1us a sample
+	lc := &LogicCapture{Samples: manchester([]byte{1, 1, 0, 1, 0, 0, 1, 0}, 8), Interval: 1e-6}
+	d := ManchesterDecoder{Pin: 0, BitPeriod: 8 * time.Microsecond, Convention: ManchesterIEEE}
+	if err := RegisterDecoder("test-manchester", d); err != nil {
+		t.Fatal(err)
+	}
+	events, err = Decode("test-manchester", lc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Value != "0xD2" || events[0].Time != 4e-6 {
+		t.Errorf("got %+v, want 0xD2 at 4us", events)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ExportOption changes how ExportGnuplot and ExportAllChannelsCSV write
+type ExportOption func(*exportOptions)
+
+type exportOptions struct {
+	triggerAtZero bool
+}
+
+// WithTriggerAtZero writes times from TriggerTime, so the trigger sample is at
+// exactly t=0, rather than the Xorigin based SampleTime
+func WithTriggerAtZero() ExportOption {
+	return func(o *exportOptions) {
+		o.triggerAtZero = true
+	}
+}
+
+// sampleTimes returns the function giving the time of each sample of p, or an
+// error if p's Xincrement can't give times
+func sampleTimes(p *Preamble, opts []ExportOption) (func(int64) float64, error) {
+	if err := p.checkXincrement(); err != nil {
+		return nil, err
+	}
+	var o exportOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.triggerAtZero {
+		return p.TriggerTime, nil
+	}
+	return p.SampleTime, nil
+}
+
+// ExportGnuplot writes BYTE format waveform data as space separated time (from
+// the trigger, see SampleTime and WithTriggerAtZero) and value (in p.Unit)
+// columns, with the preamble in # comments at the top, ready for gnuplot's
+// plot "file" using 1:2 with lines
+func ExportGnuplot(w io.Writer, p *Preamble, raw []byte, opts ...ExportOption) error {
+	sampleTime, err := sampleTimes(p, opts)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# preamble: %s\n", p)
+	fmt.Fprintf(bw, "# %d points, %ss per point, %ss in total\n", len(raw), siPrefix(p.Xincrement), siPrefix(float64(len(raw))*p.Xincrement))
+	fmt.Fprintf(bw, "# %s%s per code\n", siPrefix(p.Yincrement), p.Unit.Symbol())
+	fmt.Fprintf(bw, "# trigger at sample %d\n", p.triggerSampleIndex())
+	fmt.Fprintf(bw, "# time(s) value(%s)\n", p.Unit.Symbol())
+	for i, v := range p.Volts(raw) {
+		fmt.Fprintf(bw, "%g %g\n", sampleTime(int64(i)), v)
+	}
+	return bw.Flush()
+}
+
+// ExportDecodeCSV writes decode events as CSV with a header row and time (in
+// seconds from the trigger), type and value columns
+func ExportDecodeCSV(w io.Writer, events []DecodeEvent) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "type", "value"})
+	for _, e := range events {
+		cw.Write([]string{strconv.FormatFloat(e.Time, 'g', -1, 64), e.Type, e.Value})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportAllChannelsCSV stops the scope, reads every displayed analog channel
+// from the same acquisition in full (setting it running again afterwards if
+// it was) and writes them as CSV: a time column (from the trigger, see
+// ExportGnuplot) then one column per channel, labelled with the channel's unit.
+// Waveform data must be in BYTE format.
+func (r *Rigol) ExportAllChannelsCSV(w io.Writer, opts ...ExportOption) error {
+	if r.waveformFormat() != FormatByte {
+		return fmt.Errorf("waveform format must be %s to convert to volts", FormatByte)
+	}
+	header := []string{"time"}
+	var volts [][]float64
+	var p *Preamble
+	err := r.whilePaused(func() error {
+		for ch := 1; ch <= 4; ch++ {
+			disp, err := r.Query(fmt.Sprintf(":CHAN%d:DISP?", ch))
+			if err != nil {
+				return err
+			}
+			if disp != "1" {
+				continue
+			}
+			data, chp, err := r.acquire(SourceCH(ch))
+			if err != nil {
+				return fmt.Errorf("reading CH%d: %v", ch, err)
+			}
+			if p != nil && chp.Points != p.Points {
+				return fmt.Errorf("CH%d has %d points, expected %d", ch, chp.Points, p.Points)
+			}
+			p = chp
+			header = append(header, fmt.Sprintf("CH%d(%s)", ch, chp.Unit.Symbol()))
+			volts = append(volts, chp.Volts(data))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return errors.New("no analog channels are displayed")
+	}
+
+	sampleTime, err := sampleTimes(p, opts)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	row := make([]string, len(header))
+	for i := range volts[0] {
+		row[0] = strconv.FormatFloat(sampleTime(int64(i)), 'g', -1, 64)
+		for c, v := range volts {
+			row[c+1] = strconv.FormatFloat(v[i], 'g', -1, 64)
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// fakeScope is a Transport that answers each command with handle, for tests
+// that drive Rigol methods without a scope
+type fakeScope struct {
+	handle  func(msg string) []byte // response to msg, nil for none
+	written []string                // every command, compound messages split up
+	queue   [][]byte                // responses not yet read
+}
+
+func (f *fakeScope) Write(b []byte) error {
+	for _, msg := range strings.Split(strings.TrimSpace(string(b)), ";") {
+		f.written = append(f.written, msg)
+		if resp := f.handle(msg); resp != nil {
+			f.queue = append(f.queue, resp)
+		}
+	}
+	return nil
+}
+
+// Read returns up to n bytes of the oldest response, leaving any more of it
+// for the next Read
+func (f *fakeScope) Read(n uint32) ([]byte, error) {
+	if len(f.queue) == 0 {
+		return nil, errors.New("read with no response pending")
+	}
+	resp := f.queue[0]
+	if uint32(len(resp)) > n {
+		f.queue[0] = resp[n:]
+		return resp[:n], nil
+	}
+	f.queue = f.queue[1:]
+	return resp, nil
+}
+
+func (f *fakeScope) Close() error { return nil }
+
+// replies answers queries from a table, each giving its responses in turn with
+// the last repeated. Commands that aren't queries get no response.
+func replies(table map[string][]string) func(string) []byte {
+	return func(msg string) []byte {
+		if !strings.Contains(msg, "?") {
+			return nil
+		}
+		resps, ok := table[msg]
+		if !ok || len(resps) == 0 {
+			return []byte("\n")
+		}
+		resp := resps[0]
+		if len(resps) > 1 {
+			table[msg] = resps[1:]
+		}
+		return []byte(resp + "\n")
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import "time"
+
+// historyResponseLimit is how much of each response History keeps, enough for
+// any text reply without holding on to whole waveforms
+const historyResponseLimit = 256
+
+// Transaction is a command sent to the scope and whatever was read back after it
+type Transaction struct {
+	Time        time.Time
+	Command     string
+	Response    []byte // first 256 bytes of the response, if any
+	ResponseLen int    // full length of the response
+	Err         error  // from the write or read
+}
+
+// history is a ring buffer of the last transactions
+type history struct {
+	entries []Transaction
+	next    int
+	full    bool
+}
+
+// WithHistory keeps the last n commands and their responses, see History
+func WithHistory(n int) Option {
+	return func(o *options) {
+		o.history = n
+	}
+}
+
+// History returns the recorded transactions, oldest first. It is empty unless
+// Init was given WithHistory.
+func (r *Rigol) History() []Transaction {
+	h := r.history
+	if h == nil {
+		return nil
+	}
+	if !h.full {
+		return append([]Transaction(nil), h.entries[:h.next]...)
+	}
+	return append(append([]Transaction(nil), h.entries[h.next:]...), h.entries[:h.next]...)
+}
+
+func (r *Rigol) recordWrite(msg string, err error) {
+	h := r.history
+	if h == nil {
+		return
+	}
+	h.entries[h.next] = Transaction{Time: time.Now(), Command: msg, Err: err}
+	h.next++
+	if h.next == len(h.entries) {
+		h.next = 0
+		h.full = true
+	}
+}
+
+// recordRead attaches a response to the most recent command
+func (r *Rigol) recordRead(b []byte, err error) {
+	h := r.history
+	if h == nil || (h.next == 0 && !h.full) {
+		return
+	}
+	last := h.next - 1
+	if last < 0 {
+		last = len(h.entries) - 1
+	}
+	t := &h.entries[last]
+	room := historyResponseLimit - len(t.Response)
+	if room > len(b) {
+		room = len(b)
+	}
+	t.Response = append(t.Response, b[:room]...)
+	t.ResponseLen += len(b)
+	if err != nil {
+		t.Err = err
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// PodConfig is the setup for one 8 channel logic analyser pod (POD1 is D0-D7,
+// POD2 is D8-D15)
+type PodConfig struct {
+	Enable    bool
+	Threshold float64 // voltage for logic 1, -15v to 15v
+}
+
+// ConfigureLA turns the LA on and sets up each pod in order, pods[0] is POD1.
+// The LA is turned off altogether if no pod is enabled. Enabling a pod on a
+// model without the LA returns ErrUnsupported. The pods share the memory with
+// the analog channels, so an error is returned if the current memory depth
+// isn't possible with them on.
+func (r *Rigol) ConfigureLA(pods []PodConfig) error {
+	if len(pods) == 0 || len(pods) > 2 {
+		return fmt.Errorf("expected 1 or 2 LA pods, got %d", len(pods))
+	}
+	enabled := false
+	for i, pod := range pods {
+		if pod.Threshold < -15 || pod.Threshold > 15 {
+			return fmt.Errorf("POD%d threshold %gv out of range -15v to 15v", i+1, pod.Threshold)
+		}
+		enabled = enabled || pod.Enable
+	}
+	if !enabled {
+		return r.Write(":LA:STAT OFF")
+	}
+	caps, err := r.Capabilities()
+	if err != nil {
+		return err
+	}
+	if !caps.HasLA {
+		return fmt.Errorf("logic analyser on %s: %w", caps.Model, ErrUnsupported)
+	}
+
+	setup := []string{":LA:STAT ON"}
+	for i, pod := range pods {
+		setup = append(setup,
+			cmd(fmt.Sprintf(":LA:POD%d:DISP", i+1), pod.Enable),
+			cmd(fmt.Sprintf(":LA:POD%d:THR", i+1), pod.Threshold),
+		)
+	}
+	if err := r.WriteAll(setup); err != nil {
+		return err
+	}
+	return r.checkCurrentMemoryDepth()
+}
+
+// checkCurrentMemoryDepth returns an error unless :ACQ:MDEP is AUTO or one of
+// MemoryDepths with the channels now turned on
+func (r *Rigol) checkCurrentMemoryDepth() error {
+	mdep, err := r.Query(":ACQ:MDEP?")
+	if err != nil {
+		return err
+	}
+	if mdep == "AUTO" {
+		return nil
+	}
+	depth, err := strconv.ParseInt(mdep, 10, 64)
+	if err != nil {
+		return fmt.Errorf("unexpected memory depth %q", mdep)
+	}
+	depths, err := r.MemoryDepths()
+	if err != nil {
+		return err
+	}
+	return checkMemoryDepth(depth, depths, "the LA pods enabled")
+}
+
+// maxMemoryDepth returns the deepest :ACQ:MDEP allowed with the given number of
+// channel groups in use, on a scope with total points of memory (its
+// Capabilities.MaxMemory). Each analog channel and each enabled LA pod takes a
+// group, and the memory is shared between them (so 6M points of 24M with CH1
+// and 16 LA channels on).
+func maxMemoryDepth(total int64, groups int) int64 {
+	switch {
+	case groups <= 1:
+		return total
+	case groups == 2:
+		return total / 2
+	default:
+		return total / 4
+	}
+}
+
+// memoryDepths returns the :ACQ:MDEP values the scope accepts with the given
+// number of channel groups in use, shallowest first: 12k, 120k, 1.2M and 12M
+// points for one group, half that for two and a quarter for three or four,
+// then the whole of the group's share of memory if that's deeper
+func memoryDepths(total int64, groups int) []int64 {
+	split := int64(1)
+	if groups == 2 {
+		split = 2
+	} else if groups > 2 {
+		split = 4
+	}
+	max := maxMemoryDepth(total, groups)
+	var depths []int64
+	for d := 12000 / split; d <= max; d *= 10 {
+		depths = append(depths, d)
+	}
+	if depths[len(depths)-1] != max {
+		depths = append(depths, max)
+	}
+	return depths
+}
+
+// validateMemoryDepth checks depth is one the scope accepts with the given
+// number of analog channels and LA pods turned on, on a scope with total points
+// of memory
+func validateMemoryDepth(depth, total int64, analog int, pods []PodConfig) error {
+	if depth <= 0 {
+		return errors.New("memory depth must be positive")
+	}
+	groups := analog
+	for _, pod := range pods {
+		if pod.Enable {
+			groups++
+		}
+	}
+	return checkMemoryDepth(depth, memoryDepths(total, groups),
+		fmt.Sprintf("%d analog channels and %d LA channels enabled", analog, 8*(groups-analog)))
+}
+
+// checkMemoryDepth returns an error unless depth is one of depths, describing
+// the channels turned on with setup
+func checkMemoryDepth(depth int64, depths []int64, setup string) error {
+	for _, d := range depths {
+		if d == depth {
+			return nil
+		}
+	}
+	return fmt.Errorf("memory depth %d not possible with %s, expected one of %v", depth, setup, depths)
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestValidateMemoryDepth(t *testing.T) {
+	pod := []PodConfig{{Enable: true, Threshold: 1.4}}
+	tests := []struct {
+		depth, total int64
+		analog       int
+		pods         []PodConfig
+		ok           bool
+	}{
+		{24000000, 24000000, 1, nil, true},
+		{24000000, 12000000, 1, nil, false}, // no memory option
+		{12000000, 12000000, 1, nil, true},
+		{12000000, 24000000, 1, pod, true},
+		{12000000, 12000000, 1, pod, false},
+		{6000000, 24000000, 4, nil, true},
+		{6000000, 12000000, 4, nil, false},
+		{125000, 24000000, 1, pod, false}, // between steps
+		{60000, 24000000, 1, pod, true},
+		{120000, 12000000, 1, nil, true},
+		{3000, 12000000, 3, nil, true},
+	}
+	for _, tt := range tests {
+		err := validateMemoryDepth(tt.depth, tt.total, tt.analog, tt.pods)
+		if (err == nil) != tt.ok {
+			t.Errorf("depth %d of %d with %d channels and %d pods: got %v", tt.depth, tt.total, tt.analog, len(tt.pods), err)
+		}
+	}
+}
+
+func TestConfigureLAChecksMemoryDepth(t *testing.T) {
+	both := []PodConfig{{Enable: true, Threshold: 1.4}, {Enable: true, Threshold: 3.3}}
+	tests := []struct {
+		mdep string
+		ok   bool
+	}{
+		{"AUTO", true},
+		{"6000000", true},
+		{"12000000", false}, // CH1 and both pods share 24M three ways
+	}
+	for _, tt := range tests {
+		f := &fakeScope{handle: replies(map[string][]string{
+			"*IDN?":          {"RIGOL TECHNOLOGIES,MSO1104Z-S,DS1ZC000000000,00.04.04.SP3"},
+			":ACQ:MDEP?":     {tt.mdep},
+			":CHAN1:DISP?":   {"1"},
+			":LA:STAT?":      {"1"},
+			":LA:POD1:DISP?": {"1"},
+			":LA:POD2:DISP?": {"1"},
+		})}
+		r := &Rigol{Transport: f}
+		err := r.ConfigureLA(both)
+		if (err == nil) != tt.ok {
+			t.Errorf("memory depth %s: got %v", tt.mdep, err)
+		}
+	}
+}
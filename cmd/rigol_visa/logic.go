@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// LogicCapture is logic analyser data ready for decoding. Each sample is one
+// pod's worth of channels, with the lowest channel (D0 or D8) in bit 0.
+type LogicCapture struct {
+	Samples  []byte
+	Interval float64 // seconds between samples
+	Start    float64 // time of the first sample in seconds from the trigger
+}
+
+// ReplayCapture turns a saved capture of an LA pod back into a LogicCapture,
+// so decoders can be run on archived data without a scope
+func ReplayCapture(c *Capture) *LogicCapture {
+	lc := &LogicCapture{Samples: c.Data}
+	if c.Preamble != nil {
+		lc.Interval = c.Preamble.Xincrement
+		lc.Start = c.Preamble.SampleTime(0)
+	}
+	return lc
+}
+
+// FetchLogic16 stops the scope and reads both LA pods, combining them into one
+// sample per point with D0 in bit 0 and D15 in bit 15. Both pods must be
+// turned on. Both pods are read from the same acquisition, then the scope is
+// set running again if it was.
+func (r *Rigol) FetchLogic16() ([]uint16, error) {
+	var low, high []byte
+	err := r.whilePaused(func() (err error) {
+		if low, _, err = r.acquire(SourceDigital(0)); err != nil {
+			return fmt.Errorf("reading D0-D7: %v", err)
+		}
+		if high, _, err = r.acquire(SourceDigital(8)); err != nil {
+			return fmt.Errorf("reading D8-D15: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(low) != len(high) {
+		return nil, fmt.Errorf("pods returned %d and %d points", len(low), len(high))
+	}
+	samples := make([]uint16, len(low))
+	for i := range samples {
+		samples[i] = uint16(high[i])<<8 | uint16(low[i])
+	}
+	return samples, nil
+}
+
+// Edges returns the indices of the samples in data where pin (0-7) changes
+// level. With debounce above 1, a new level has to hold for that many samples
+// to count, so shorter glitches, bounce and ringing are ignored and the edge is
+// placed at the start of the settled level. A change too close to the end of
+// data to have held that long isn't counted.
+func Edges(data []byte, pin int, debounce int) ([]int64, error) {
+	if pin < 0 || pin > 7 {
+		return nil, fmt.Errorf("invalid pin %d, expected 0-7", pin)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	bit := func(i int) byte { return (data[i] >> pin) & 1 }
+	var edges []int64
+	level := bit(0)
+	for i := 1; i < len(data); i++ {
+		if bit(i) == level {
+			continue
+		}
+		stable := true
+		for j := i + 1; j < i+debounce; j++ {
+			if j >= len(data) || bit(j) == level {
+				stable = false
+				break
+			}
+		}
+		if stable {
+			edges = append(edges, int64(i))
+			level = bit(i)
+		}
+	}
+	return edges, nil
+}
+
+// DutyCycle returns the fraction (0-1) of the samples in data where pin (0-7)
+// is high. The capture rarely starts and ends at the same point of a PWM
+// period, so when there are two or more rising edges only the whole periods
+// between the first and last of them are counted. Otherwise, as for a line
+// that's stuck or only switches once, it covers the whole capture.
+func DutyCycle(data []byte, pin int) (float64, error) {
+	if pin < 0 || pin > 7 {
+		return 0, fmt.Errorf("invalid pin %d, expected 0-7", pin)
+	}
+	if len(data) == 0 {
+		return 0, errors.New("no samples")
+	}
+	edges, err := Edges(data, pin, 0)
+	if err != nil {
+		return 0, err
+	}
+	var rising []int64
+	for _, e := range edges {
+		if (data[e]>>pin)&1 == 1 {
+			rising = append(rising, e)
+		}
+	}
+	start, end := int64(0), int64(len(data))
+	if len(rising) >= 2 {
+		start, end = rising[0], rising[len(rising)-1]
+	}
+	high := 0
+	for _, b := range data[start:end] {
+		high += int((b >> pin) & 1)
+	}
+	return float64(high) / float64(end-start), nil
+}
+
+// Manchester conventions, the edge in the middle of a bit that means 1
+const (
+	ManchesterIEEE   = 0 // IEEE 802.3, low to high is 1
+	ManchesterThomas = 1 // G.E. Thomas, high to low is 1
+)
+
+// ManchesterDecode recovers the bits sent on pin (0-7) of LA data, bitPeriod
+// samples per bit. The clock is taken from the mid-bit transitions, ignoring
+// those on bit boundaries, so it tracks some drift in the sender's rate. At the
+// start, and after an idle gap, the phase is found from the first pair of edges
+// a whole bit period apart, which are both mid-bit. Bits are packed MSB first,
+// a final partial byte is padded with 0s.
+func ManchesterDecode(data []byte, pin int, bitPeriod int64, convention int) ([]byte, error) {
+	out, _, err := manchesterDecode(data, pin, bitPeriod, convention)
+	return out, err
+}
+
+// manchesterDecode is ManchesterDecode, also returning the sample index of the
+// mid-bit edge of each byte's first bit
+func manchesterDecode(data []byte, pin int, bitPeriod int64, convention int) ([]byte, []int64, error) {
+	if pin < 0 || pin > 7 {
+		return nil, nil, fmt.Errorf("invalid pin %d, expected 0-7", pin)
+	}
+	if bitPeriod < 2 {
+		return nil, nil, fmt.Errorf("bit period of %d samples is too short", bitPeriod)
+	}
+	if convention != ManchesterIEEE && convention != ManchesterThomas {
+		return nil, nil, fmt.Errorf("invalid Manchester convention %d", convention)
+	}
+	edges, err := Edges(data, pin, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(edges) == 0 {
+		return nil, nil, errors.New("no transitions on pin")
+	}
+
+	var out []byte
+	var starts []int64
+	nbits := 0
+	addBit := func(e int64) {
+		bit := (data[e] >> pin) & 1 // level after the edge, 1 for rising
+		if convention == ManchesterThomas {
+			bit ^= 1
+		}
+		if nbits%8 == 0 {
+			out = append(out, 0)
+			starts = append(starts, e)
+		}
+		out[len(out)-1] |= bit << (7 - nbits%8)
+		nbits++
+	}
+
+	i := manchesterPhase(edges, 0, bitPeriod)
+	mid := edges[i]
+	addBit(mid)
+	for i++; i < len(edges); i++ {
+		e := edges[i]
+		switch gap := e - mid; {
+		case gap > bitPeriod*5/4:
+			// idle, find the phase again
+			i = manchesterPhase(edges, i, bitPeriod)
+			e = edges[i]
+		case gap < bitPeriod*3/4:
+			// on the boundary between two equal bits
+			continue
+		}
+		addBit(e)
+		mid = e
+	}
+	return out, starts, nil
+}
+
+// manchesterPhase returns the index of the first mid-bit edge in the burst
+// starting at edges[start]. Boundary edges only come half a period from a
+// mid-bit one, so two edges a whole period apart are both mid-bit, and before
+// them the edges alternate. A burst with no such pair, a run of equal bits, is
+// taken to start on a mid-bit edge.
+func manchesterPhase(edges []int64, start int, bitPeriod int64) int {
+	for k := start + 1; k < len(edges); k++ {
+		gap := edges[k] - edges[k-1]
+		if gap > bitPeriod*5/4 {
+			break
+		}
+		if gap >= bitPeriod*3/4 {
+			return start + (k-1-start)%2
+		}
+	}
+	return start
+}
+
+// ManchesterDecoder is a Decoder for Manchester data on one LA pin, see
+// ManchesterDecode. Each byte is an event of type "Manchester" timed at the
+// middle of its first bit.
+type ManchesterDecoder struct {
+	Pin        int           // 0-7
+	BitPeriod  time.Duration // time per bit
+	Convention int           // ManchesterIEEE or ManchesterThomas
+}
+
+func (d ManchesterDecoder) Decode(capture *LogicCapture) ([]DecodeEvent, error) {
+	if capture.Interval <= 0 {
+		return nil, errors.New("capture has no sample interval")
+	}
+	period := int64(math.Round(d.BitPeriod.Seconds() / capture.Interval))
+	out, starts, err := manchesterDecode(capture.Samples, d.Pin, period, d.Convention)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]DecodeEvent, len(out))
+	for i, b := range out {
+		events[i] = DecodeEvent{
+			Time:  capture.Start + float64(starts[i])*capture.Interval,
+			Type:  "Manchester",
+			Value: fmt.Sprintf("0x%02X", b),
+		}
+	}
+	return events, nil
+}
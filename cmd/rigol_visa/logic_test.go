@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// manchester encodes bits (IEEE 802.3, 0 to 1 mid-bit is 1) on pin 0 with
+// period samples per bit
+func manchester(bits []byte, period int) []byte {
+	var data []byte
+	for _, b := range bits {
+		for i := 0; i < period; i++ {
+			level := b
+			if i < period/2 {
+				level ^= 1
+			}
+			data = append(data, level)
+		}
+	}
+	return data
+}
+
+func TestManchesterDecode(t *testing.T) {
+	data := manchester([]byte{1, 1, 0, 1, 0, 0}, 8)
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"from the start", data, []byte{0xd0}},
+		// the first edge is the boundary between the two 1s, which mustn't be
+		// taken for a mid-bit edge
+		{"from a boundary edge", data[6:], []byte{0xa0}},
+		{"after idle", append(append(data, make([]byte, 40)...), manchester([]byte{1, 0}, 8)...), []byte{0xd2}},
+	}
+	for _, tt := range tests {
+		got, err := ManchesterDecode(tt.data, 0, 8, ManchesterIEEE)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got %#x, want %#x", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEdgesDebounce(t *testing.T) {
+	// pin 1: a 1 sample glitch at 3, a clean rise at 8, bounce at 14 and a
+	// 2 sample glitch right at the end
+	levels := []byte{0, 0, 0, 1, 0, 0, 0, 0, 1, 1, 1, 1, 1, 1, 0, 1, 1, 1, 1, 1, 0, 0}
+	data := make([]byte, len(levels))
+	for i, l := range levels {
+		data[i] = l<<1 | 1 // pin 0 held high shouldn't matter
+	}
+	tests := []struct {
+		debounce int
+		want     []int64
+	}{
+		{0, []int64{3, 4, 8, 14, 15, 20}},
+		{1, []int64{3, 4, 8, 14, 15, 20}},
+		{2, []int64{8, 20}},
+		{3, []int64{8}}, // the final low only lasts 2 samples
+	}
+	for _, tt := range tests {
+		got, err := Edges(data, 1, tt.debounce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("debounce %d: got %v, want %v", tt.debounce, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("debounce %d: got %v, want %v", tt.debounce, got, tt.want)
+				break
+			}
+		}
+	}
+	for _, pin := range []int{-1, 8} {
+		if _, err := Edges(data, pin, 0); err == nil {
+			t.Errorf("pin %d: expected an error", pin)
+		}
+	}
+}
@@ -1,212 +1,323 @@
 package main
 
 import (
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 	"time"
-
-	vi "github.com/jpoirier/visa"
 )
 
 // Refer to https://www.batronix.com/files/Rigol/Oszilloskope/_DS&MSO1000Z/MSO_DS1000Z_ProgrammingGuide_EN.pdf
 
 type Rigol struct {
-	Instr           vi.Object
-	ResourceManager vi.Session
+	Transport Transport
+
+	format         WaveformFormat          // current :WAV:FORM, see SetWaveformFormat
+	mode           WaveformMode            // :WAV:MODE for fetches, see SetWaveformMode
+	logger         *log.Logger             // see WithLogger
+	writeDelay     time.Duration           // see WithWriteDelay
+	byteOrder      binary.ByteOrder        // of WORD samples, see WithByteOrder
+	history        *history                // see WithHistory
+	stopBeforeRead bool                    // see WithStopBeforeRead
+	checkErrors    bool                    // see WithErrorCheck
+	compound       bool                    // see WithCompoundCommands
+	onProgress     func(done, total int64) // see WithProgress
+	maxFetchBytes  int64                   // see WithMaxFetchBytes
+	runState       string                  // last :TRIG:STAT? other than STOP, see DidTrigger
+	forcedStop     bool                    // stop() stopped a running acquisition, see DidTrigger
+	deepMemory     bool                    // see WithDeepMemory
+	caps           *Capabilities           // from the first Capabilities call
 }
 
-func (r *Rigol) Init(connStr string) error {
-	rm, status := vi.OpenDefaultRM()
-	if status < vi.SUCCESS {
-		return errors.New("could not open a session to the VISA Resource Manager")
+// Init opens a VISA session to the scope at connStr, e.g. TCPIP::192.168.1.70::INSTR
+func (r *Rigol) Init(connStr string, opts ...Option) error {
+	o := r.applyOptions(opts)
+	t, err := openVISA(connStr, o)
+	if err != nil {
+		return err
 	}
-	r.ResourceManager = rm
+	r.Transport = t
+	return r.waitReady(o.readyWait)
+}
 
-	instr, status := rm.Open(connStr, vi.NULL, vi.NULL)
-	if status < vi.SUCCESS {
-		return fmt.Errorf("an error occurred opening the session to %s", connStr)
+// InitTCP connects to the scope's raw SCPI socket at host (port 5555 unless
+// given), which doesn't need VISA installed
+func (r *Rigol) InitTCP(host string, opts ...Option) error {
+	o := r.applyOptions(opts)
+	timeout := 10 * time.Second
+	if o.timeout > 0 {
+		timeout = o.timeout
+	}
+	t, err := DialTCPTimeout(host, timeout)
+	if err != nil {
+		return err
 	}
-	r.Instr = instr
+	r.Transport = t
+	return r.waitReady(o.readyWait)
+}
 
-	return nil
+// InitVXI11 connects to the scope at host over LXI VXI-11, which doesn't need
+// VISA installed
+func (r *Rigol) InitVXI11(host string, opts ...Option) error {
+	o := r.applyOptions(opts)
+	t, err := DialVXI11(host)
+	if err != nil {
+		return err
+	}
+	if o.timeout > 0 {
+		t.Timeout = o.timeout
+	}
+	r.Transport = t
+	return r.waitReady(o.readyWait)
+}
+
+// waitReady polls *IDN? until it gets an answer or wait has passed, closing
+// the connection if it never does. A zero wait doesn't check at all.
+func (r *Rigol) waitReady(wait time.Duration) error {
+	if wait <= 0 {
+		return nil
+	}
+	deadline := time.Now().Add(wait)
+	backoff := 100 * time.Millisecond
+	for {
+		idn, err := r.Query("*IDN?")
+		if err == nil && idn != "" {
+			return nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			r.Close()
+			if err == nil {
+				err = errors.New("empty response")
+			}
+			return fmt.Errorf("scope not ready after %v: %v", wait, err)
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > 2*time.Second {
+			backoff = 2 * time.Second
+		}
+	}
 }
 
 func (r *Rigol) Close() {
-	r.Instr.Close()
-	r.ResourceManager.Close()
+	r.Transport.Close()
 }
 
 func (r *Rigol) Write(msg string) error {
-	b := []byte(msg)
-	_, status := r.Instr.Write(b, uint32(len(b)))
-	if status < vi.SUCCESS {
-		return fmt.Errorf("error writing to the device: %v", status)
+	err := r.Transport.Write([]byte(msg))
+	r.recordWrite(msg, err)
+	return err
+}
+
+// WriteAll writes each command in turn, stopping at the first failure. With
+// WithErrorCheck it then checks the scope accepted them all.
+func (r *Rigol) WriteAll(cmds []string) error {
+	if r.checkErrors {
+		if _, err := r.ErrorQueue(); err != nil {
+			return err
+		}
+	}
+	for _, c := range cmds {
+		// before rather than between commands, so consecutive batches are spaced too
+		if r.writeDelay > 0 {
+			time.Sleep(r.writeDelay)
+		}
+		if err := r.Write(c); err != nil {
+			return err
+		}
+	}
+	if !r.checkErrors {
+		return nil
+	}
+	errs, err := r.ErrorQueue()
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("scope rejected commands in %q: %s", cmds, strings.Join(errs, "; "))
 	}
 	return nil
 }
 
 func (r *Rigol) Read(bytes uint32) ([]byte, error) {
-	b, _, status := r.Instr.Read(bytes)
-	if status < vi.SUCCESS {
-		return nil, fmt.Errorf("read failed with error code %x", status)
+	b, err := r.Transport.Read(bytes)
+	r.recordRead(b, err)
+	return b, err
+}
+
+// ReadAll reads the whole of the next response, for responses whose length
+// isn't known in advance. Transports that can't tell where a response ends
+// return at most 1MB.
+func (r *Rigol) ReadAll() ([]byte, error) {
+	t, ok := r.Transport.(readAllTransport)
+	if !ok {
+		return r.Read(1 << 20)
 	}
-	return b, nil
+	b, err := t.ReadAll()
+	r.recordRead(b, err)
+	return b, err
 }
 
-func (r *Rigol) FetchWaveformData(source string) ([]byte, []byte, error) {
-	setup := []string{
-		fmt.Sprintf(":WAV:SOUR %s", source), // waveform source
-		":WAV:MODE RAW",                     // capture all samples from memory, not just on screen
-		":WAV:FORM BYTE",                    // data format bytes
-		":WAV:STAR 1",                       // start at sample 1
-		":WAV:STOP 125000",                  // capture 125k samples (max per call)
-		":WAV:DATA?",                        // fetch data
-	}
-	for _, cmd := range setup {
-		if err := r.Write(cmd); err != nil {
-			return nil, nil, err
-		}
+// Query writes query and returns the first line of the response
+func (r *Rigol) Query(query string) (string, error) {
+	if err := r.Write(query); err != nil {
+		return "", err
+	}
+	d, err := r.Read(1024)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.Split(string(d), "\n")[0]), nil
+}
+
+// QueryFloat is Query for responses that are a single number
+func (r *Rigol) QueryFloat(query string) (float64, error) {
+	resp, err := r.Query(query)
+	if err != nil {
+		return 0, err
 	}
-	d, err := r.Read(125000)
+	f, err := strconv.ParseFloat(resp, 64)
 	if err != nil {
+		return 0, fmt.Errorf("unexpected response %q to %s", resp, query)
+	}
+	return f, nil
+}
+
+func (r *Rigol) FetchWaveformData(source Source) ([]byte, []byte, error) {
+	if err := r.selectSource(source); err != nil {
 		return nil, nil, err
 	}
 	// header, data, error
-	return d[0:11], d[11 : len(d)-1], nil
+	return r.fetchWindow(1, maxPointsPerRead)
+}
+
+// FetchWaveformWithPreamble reads every point of source along with the
+// preamble that describes them. The preamble is read after selecting the
+// source and mode and straight before the data, with no other setup in
+// between, so the two always match. Unlike Acquire it leaves the run state
+// alone, so the scope should already be stopped.
+func (r *Rigol) FetchWaveformWithPreamble(source Source) ([]byte, *Preamble, error) {
+	return r.acquire(source)
 }
 
 func (r *Rigol) Trigger() error {
+	pods := []PodConfig{
+		{Enable: true, Threshold: 3},  // D0-D7 on, logic 1 at 3v
+		{Enable: false, Threshold: 3}, // D8-D15 off
+	}
+	caps, err := r.Capabilities()
+	if err != nil {
+		return err
+	}
+	cfg := DefaultTriggerConfig()
+	if !caps.HasLA {
+		// DS models, capture CH1 alone, which has twice the depth at each step
+		pods = nil
+		cfg.MemoryDepth *= 2
+	}
+	if err := validateMemoryDepth(cfg.MemoryDepth, caps.MaxMemory, 1, pods); err != nil {
+		return err
+	}
+
 	setup := []string{
-		":CHAN1:DISP ON",        // Turn on ch1
-		":CHAN1:PROB 10",        // 10x probe
-		":CHAN1:UNIT VOLT",      // units in volts
-		":CHAN1:SCAL 1",         // 1v per division
-		":CHAN1:OFFS 0",         // 0 offset
-		":CHAN2:DISP OFF",       // Turn off ch2
-		":CHAN3:DISP OFF",       // Turn off ch3
-		":CHAN4:DISP OFF",       // Turn off ch4
-		":LA:STAT ON",           // Turn on the LA
-		":LA:POD1:DISP ON",      // turn D0-D7 on
-		":LA:POD1:THR 3",        // POD1 threshold for logic 1 at 3v
-		":LA:POD2:DISP OFF",     // turn D8-D15 off
-		":LA:POD2:THR 3",        // POD1 threshold for logic 1 at 3v
-		":TRIG:MODE EDGE",       // trigger mode to edge
-		":TRIG:EDG:SOUR CHAN1",  // trigger on Channel 1
-		":TRIG:EDG:SLOP POS",    // trigger on rising edge
-		":TRIG:EDG:LEV 3",       // trigger level set to 3v
-		":ACQ:MDEP 125000",      // Memory depth, max is 6000000 pts when 16 LA channels enabled
-		":TIM:MAIN:SCAL 0.0002", // Timebase scale in seconds
-		":ACQ:TYPE HRES",        // High resolution mode
-		":SING",                 // single shot wait for trigger
-	}
-	for _, cmd := range setup {
-		if err := r.Write(cmd); err != nil {
+		":CHAN1:DISP ON",  // Turn on ch1
+		":CHAN1:PROB 10",  // 10x probe
+		":CHAN1:SCAL 1",   // 1v per division
+		":CHAN1:OFFS 0",   // 0 offset
+		":CHAN2:DISP OFF", // Turn off ch2
+		":CHAN3:DISP OFF", // Turn off ch3
+		":CHAN4:DISP OFF", // Turn off ch4
+	}
+	if err := r.WriteAll(setup); err != nil {
+		return err
+	}
+	if pods != nil {
+		if err := r.ConfigureLA(pods); err != nil {
 			return err
 		}
 	}
-	return nil
+	return r.Arm(cfg)
+}
+
+// CaptureResult reports how a WaitForCapture went, whether or not it succeeded
+type CaptureResult struct {
+	Elapsed time.Duration // from the call until the scope stopped, or gave up
+	Polls   int           // :TRIG:STAT? queries made
 }
 
-func (r *Rigol) WaitForCapture() error {
+// WaitForCapture polls once a second, for up to a minute, until the scope stops
+func (r *Rigol) WaitForCapture() (CaptureResult, error) {
+	var res CaptureResult
+	start := time.Now()
 	for i := 0; i < 60; i++ {
 		time.Sleep(1 * time.Second)
 
-		if err := r.Write("TRIG:STAT?"); err != nil {
-			return err
-		}
-		d, err := r.Read(100)
+		state, err := r.triggerStatus()
+		res.Polls++
+		res.Elapsed = time.Since(start)
 		if err != nil {
-			return err
+			return res, err
 		}
-		state := strings.Split(string(d), "\n")[0]
 		if state == "STOP" {
-			return nil
+			return res, nil
 		}
 	}
-	return errors.New("timeout waiting for trigger")
+	return res, errors.New("timeout waiting for trigger")
 }
 
-type Preamble struct {
-	Format     int64   // 0 byte, 1 word, 2 asc
-	Type       int64   // 0 normal, 1 max, 2 raw
-	Points     int64   // number of points
-	Count      int64   // the number of averages in the average sample mode and 1 in other modes
-	Xincrement float64 // time diff between points
-	Xorigin    float64 // start time of waveform
-	Xref       int64   // Reference time of data point
-	Yincrement float64 // waveform increment in Y
-	Yorigin    int64   // vertical offset
-	Yref       int64   // vertical reference position
-}
+// ErrNoTrigger is returned by WaitForTrigger when the scope stopped without
+// having triggered: in AUTO sweep without TD being seen, or stopped by a fetch
+// (see WithStopBeforeRead) before a trigger. See WaitForTrigger for the stops
+// it can't detect.
+var ErrNoTrigger = errors.New("acquisition stopped without a trigger")
 
-func (r *Rigol) FetchPreamble() (*Preamble, error) {
-	err := r.Write(":WAV:PRE?")
-	if err != nil {
-		return nil, err
+// WaitForTrigger waits for the scope to stop, like WaitForCapture, but only
+// returns nil if it triggered. It polls every 50ms and decides as DidTrigger
+// does: it triggered if TD was seen, not if this package stopped the scope, and
+// otherwise it did in single and normal sweep, which only capture on a
+// trigger, but not in AUTO sweep, where a short TD can be missed. A stop from
+// the front panel or another connection in single or normal sweep looks the
+// same as a trigger, so it returns nil for that too.
+func (r *Rigol) WaitForTrigger(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	triggered := false
+	for {
+		state, err := r.triggerStatus()
+		if err != nil {
+			return err
+		}
+		switch state {
+		case "TD":
+			triggered = true
+		case "STOP":
+			if triggered {
+				return nil
+			}
+			if r.forcedStop {
+				return ErrNoTrigger
+			}
+			sweep, err := r.Query(":TRIG:SWE?")
+			if err != nil {
+				return err
+			}
+			if sweep == "AUTO" {
+				return ErrNoTrigger
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
 	}
-	preamble, err := r.Read(100)
-	if err != nil {
-		return nil, err
-	}
-	preambleStr := strings.Split(string(preamble), "\n")[0]
-	fmt.Printf("Raw Preamble: %s\n", preambleStr)
-	p := &Preamble{}
-	parts := strings.Split(preambleStr, ",")
-	if pf, err := strconv.ParseInt(parts[0], 10, 64); err != nil {
-		return nil, err
-	} else {
-		p.Format = pf
-	}
-	if pt, err := strconv.ParseInt(parts[1], 10, 64); err != nil {
-		return nil, err
-	} else {
-		p.Type = pt
-	}
-	if pp, err := strconv.ParseInt(parts[2], 10, 64); err != nil {
-		return nil, err
-	} else {
-		p.Points = pp
-	}
-	if pc, err := strconv.ParseInt(parts[3], 10, 64); err != nil {
-		return nil, err
-	} else {
-		p.Count = pc
-	}
-	if pxi, err := strconv.ParseFloat(parts[4], 64); err != nil {
-		return nil, err
-	} else {
-		p.Xincrement = pxi
-	}
-	if pxo, err := strconv.ParseFloat(parts[5], 64); err != nil {
-		return nil, err
-	} else {
-		p.Xorigin = pxo
-	}
-	if pxr, err := strconv.ParseInt(parts[6], 10, 64); err != nil {
-		return nil, err
-	} else {
-		p.Xref = pxr
-	}
-	if pyi, err := strconv.ParseFloat(parts[7], 64); err != nil {
-		return nil, err
-	} else {
-		p.Yincrement = pyi
-	}
-	if pyo, err := strconv.ParseInt(parts[8], 10, 64); err != nil {
-		return nil, err
-	} else {
-		p.Yorigin = pyo
-	}
-	if pyr, err := strconv.ParseInt(parts[9], 10, 64); err != nil {
-		return nil, err
-	} else {
-		p.Yref = pyr
-	}
-
-	return p, nil
 }
 
 func main() {
@@ -225,29 +336,18 @@ func main() {
 	}
 
 	log.Println("Waiting for trigger...")
-	err = r.WaitForCapture()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	log.Println("Trigger detected, fetching waveform data...")
-	header, data, err := r.FetchWaveformData("D0") // D0 for bottom 8 bits, D8 for upper
+	res, err := r.WaitForCapture()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	preamble, err := r.FetchPreamble()
+	log.Printf("Trigger detected after %v, fetching waveform data...", res.Elapsed)
+	data, preamble, err := r.FetchWaveformWithPreamble(SourceDigital(0)) // D0 for bottom 8 bits, D8 for upper
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Printf("Points: %d\n", preamble.Points)
-	fmt.Printf("Xincrement: %.9f\n", preamble.Xincrement)
-
-	fmt.Println("Header:")
-	for _, b := range header {
-		fmt.Printf("%x ", b)
-	}
-	fmt.Printf("\nData: (%d samples)\n", len(data))
+	preamble.Dump(os.Stdout)
+	fmt.Printf("Data: (%d samples)\n", len(data))
 
 	/*
 		D0 RD ULA 3
@@ -276,7 +376,7 @@ func main() {
 			transitions[timestamp] = b
 			// store whether a specific pin changed at this time
 			for i := 0; i < len(pins); i++ {
-				if b&1<<i != last&1<<i {
+				if (b>>i)&1 != (last>>i)&1 {
 					signalChanges[i] = timestamp
 				}
 			}
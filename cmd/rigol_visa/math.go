@@ -0,0 +1,89 @@
+package main
+
+import "fmt"
+
+// SetFFTWindow sets the window function used by the math channel's FFT, one of
+// RECT, HANN, HAMM or BLAC
+func (r *Rigol) SetFFTWindow(window string) error {
+	switch window {
+	case "RECT", "HANN", "HAMM", "BLAC":
+	default:
+		return fmt.Errorf("invalid FFT window %q, expected RECT, HANN, HAMM or BLAC", window)
+	}
+	return r.Write(cmd(":MATH:FFT:WIND", window))
+}
+
+// FetchMathWaveform reads the on screen math channel data and its preamble.
+// The math channel can only be read in NORM mode. When the math operator is
+// FFT the X axis is frequency, so the preamble's Xorigin and Xincrement are
+// replaced with the start frequency and bin spacing in Hz, worked out from the
+// FFT span and centre.
+func (r *Rigol) FetchMathWaveform() ([]byte, *Preamble, error) {
+	data, p, err := r.FetchScreenWaveform(SourceMath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	op, err := r.Query(":MATH:OPER?")
+	if err != nil {
+		return nil, nil, err
+	}
+	if op == "FFT" {
+		span, err := r.QueryFloat(":MATH:FFT:HSP?")
+		if err != nil {
+			return nil, nil, err
+		}
+		center, err := r.QueryFloat(":MATH:FFT:HCEN?")
+		if err != nil {
+			return nil, nil, err
+		}
+		p.Xincrement = span / float64(p.Points)
+		p.Xorigin = center - span/2
+		p.Xref = 0
+	}
+	return data, p, nil
+}
+
+// FetchMathData reads the on screen math channel as values, along with its
+// preamble from FetchMathWaveform. The math channel has its own vertical
+// scaling in the preamble, so Volts gives the right values, but they aren't
+// always volts: p.Unit is set to dB or V for an FFT depending on
+// :MATH:FFT:UNIT, the first source's unit for A+B and A-B, and unknown for the
+// other operators.
+func (r *Rigol) FetchMathData() ([]float64, *Preamble, error) {
+	data, p, err := r.FetchMathWaveform()
+	if err != nil {
+		return nil, nil, err
+	}
+	return p.Volts(data), p, nil
+}
+
+// mathUnit works out the unit of the math channel's values
+func (r *Rigol) mathUnit() (Unit, error) {
+	op, err := r.Query(":MATH:OPER?")
+	if err != nil {
+		return "", err
+	}
+	switch op {
+	case "FFT":
+		u, err := r.Query(":MATH:FFT:UNIT?")
+		if err != nil {
+			return "", err
+		}
+		if u == "DB" {
+			return UnitDecibel, nil
+		}
+		return UnitVolt, nil
+	case "ADD", "SUBT":
+		src, err := r.Query(":MATH:SRC1?")
+		if err != nil {
+			return "", err
+		}
+		kind, ch, err := Source(src).parse()
+		if err != nil || kind != "CHAN" {
+			return UnitUnknown, nil
+		}
+		return r.ChannelUnit(ch)
+	}
+	return UnitUnknown, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EnableMeasureStats shows or hides the measurement statistics, which the
+// scope accumulates over acquisitions for each item added with MeasureStats
+func (r *Rigol) EnableMeasureStats(on bool) error {
+	return r.Write(cmd(":MEAS:STAT:DISP", on))
+}
+
+// ResetMeasureStats clears the accumulated statistics
+func (r *Rigol) ResetMeasureStats() error {
+	return r.Write(":MEAS:STAT:RES")
+}
+
+// MeasureStats adds measurement item (e.g. PER, FREQ, VPP) of source to the
+// statistics if it isn't already there, then reads back the current value and
+// the min, max, mean and standard deviation seen since the last reset
+func (r *Rigol) MeasureStats(item string, source Source) (cur, min, max, mean, sdev float64, err error) {
+	if err = r.checkSource(source); err != nil {
+		return
+	}
+	if err = r.Write(cmd(":MEAS:STAT:ITEM", item, source)); err != nil {
+		return
+	}
+	stat := func(typ string) float64 {
+		if err != nil {
+			return 0
+		}
+		var v float64
+		v, err = r.QueryFloat(cmd(":MEAS:STAT:ITEM?", typ, item, source))
+		return v
+	}
+	cur = stat("CURR")
+	min = stat("MIN")
+	max = stat("MAX")
+	mean = stat("AVER")
+	sdev = stat("DEV")
+	if err != nil {
+		return 0, 0, 0, 0, 0, err
+	}
+	return cur, min, max, mean, sdev, nil
+}
+
+// MeasureRequest is one measurement for MeasureMany
+type MeasureRequest struct {
+	Item   string // e.g. VPP, FREQ, PER
+	Source Source
+}
+
+// Key is how the request's result is keyed by MeasureMany, e.g. VPP,CHAN1
+func (m MeasureRequest) Key() string {
+	return fmt.Sprintf("%s,%s", m.Item, m.Source)
+}
+
+// Measure reads a single measurement item of source
+func (r *Rigol) Measure(item string, source Source) (float64, error) {
+	if err := r.checkSource(source); err != nil {
+		return 0, err
+	}
+	return r.QueryFloat(cmd(":MEAS:ITEM?", item, source))
+}
+
+// MeasureMany reads several measurements, keyed by MeasureRequest.Key. With
+// WithCompoundCommands they're sent as one compound query and come back in
+// one response, a single round trip however many there are. Otherwise they're
+// queried one after another.
+func (r *Rigol) MeasureMany(items []MeasureRequest) (map[string]float64, error) {
+	results := make(map[string]float64, len(items))
+	if !r.compound {
+		for _, m := range items {
+			v, err := r.Measure(m.Item, m.Source)
+			if err != nil {
+				return nil, fmt.Errorf("measuring %s: %v", m.Key(), err)
+			}
+			results[m.Key()] = v
+		}
+		return results, nil
+	}
+
+	queries := make([]string, len(items))
+	for i, m := range items {
+		if err := r.checkSource(m.Source); err != nil {
+			return nil, err
+		}
+		queries[i] = cmd(":MEAS:ITEM?", m.Item, m.Source)
+	}
+	if err := r.Write(strings.Join(queries, ";")); err != nil {
+		return nil, err
+	}
+	resp, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	values := strings.Split(strings.TrimSpace(string(resp)), ";")
+	if len(values) != len(items) {
+		return nil, fmt.Errorf("expected %d measurements, got %d in %q", len(items), len(values), resp)
+	}
+	for i, m := range items {
+		v, err := strconv.ParseFloat(strings.TrimSpace(values[i]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected response %q measuring %s", values[i], m.Key())
+		}
+		results[m.Key()] = v
+	}
+	return results, nil
+}
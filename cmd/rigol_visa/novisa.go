@@ -0,0 +1,14 @@
+//go:build novisa
+
+package main
+
+import "errors"
+
+// Built with -tags novisa there is no NI-VISA dependency at all, only the raw
+// TCP transport is available
+
+type visaOptions struct{}
+
+func openVISA(connStr string, o options) (Transport, error) {
+	return nil, errors.New("built without VISA support, use InitTCP to connect to " + connStr)
+}
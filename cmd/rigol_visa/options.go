@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"time"
+)
+
+// Option configures the connection made by Init, InitTCP or InitVXI11
+type Option func(*options)
+
+type options struct {
+	timeout        time.Duration // 0 keeps the transport's default
+	logger         *log.Logger
+	termChar       byte
+	termCharSet    bool
+	writeDelay     time.Duration
+	byteOrder      binary.ByteOrder
+	history        int
+	stopBeforeRead bool
+	checkErrors    bool
+	compound       bool
+	onProgress     func(done, total int64)
+	maxFetchBytes  int64
+	readyWait      time.Duration
+	deepMemory     bool
+	visa           visaOptions
+}
+
+// WithTimeout sets the I/O timeout for each read and write, and for InitTCP
+// also the time allowed to connect
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithLogger sends warnings to l instead of the standard logger
+func WithLogger(l *log.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithTermChar makes VISA reads stop at c. Only applies to Init, the TCP and
+// VXI-11 transports always read to the end of the response.
+func WithTermChar(c byte) Option {
+	return func(o *options) {
+		o.termChar = c
+		o.termCharSet = true
+	}
+}
+
+// WithWriteDelay pauses for d before each command sent by WriteAll (and so
+// Trigger), for older scopes that drop commands sent back to back
+func WithWriteDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.writeDelay = d
+	}
+}
+
+// WithByteOrder sets the byte order of WORD format samples, for firmware that
+// doesn't send them little-endian as the DS1000Z programming guide describes
+func WithByteOrder(order binary.ByteOrder) Option {
+	return func(o *options) {
+		o.byteOrder = order
+	}
+}
+
+// WithStopBeforeRead makes RAW waveform fetches stop the scope, and wait for it
+// to report STOP, before reading. Otherwise fetching from a running scope
+// fails or returns a partial acquisition.
+func WithStopBeforeRead() Option {
+	return func(o *options) {
+		o.stopBeforeRead = true
+	}
+}
+
+// WithErrorCheck makes WriteAll read the scope's error queue after sending a
+// batch, returning an error listing anything the scope rejected. The queue is
+// emptied before the batch too, so earlier errors aren't blamed on it.
+func WithErrorCheck() Option {
+	return func(o *options) {
+		o.checkErrors = true
+	}
+}
+
+// WithCompoundCommands sends the :WAV:STAR, :WAV:STOP and :WAV:DATA? for each
+// chunk of a waveform read as one ;-separated message. Each write is a round
+// trip on VISA and VXI-11, so this cuts the per-chunk latency of deep fetches.
+// Requesting the next chunk before the last has been read would have the scope
+// discard the unread response, so this is as far as pipelining can go.
+// MeasureMany uses it too, to send all its queries in one message. Not all
+// firmware accepts compound messages, so it's off by default.
+func WithCompoundCommands() Option {
+	return func(o *options) {
+		o.compound = true
+	}
+}
+
+// WithProgress has fn called after each chunk of a multi-chunk waveform read,
+// as done by FetchFullWaveform and Acquire, with the bytes read so far and the
+// total expected. It's called from the goroutine doing the read.
+func WithProgress(fn func(done, total int64)) Option {
+	return func(o *options) {
+		o.onProgress = fn
+	}
+}
+
+// WithReadyWait makes Init, InitTCP and InitVXI11 poll *IDN?, backing off from
+// 100ms to 2s between tries, until the scope answers or d has passed. A scope
+// that has just booted or rejoined the network accepts a connection some time
+// before it answers queries.
+func WithReadyWait(d time.Duration) Option {
+	return func(o *options) {
+		o.readyWait = d
+	}
+}
+
+// WithDeepMemory says the 24M point memory option is installed on a DS1000Z
+// model that has 12M as standard. It can't be told from *IDN?, so without this
+// Capabilities reports 12M and memory depths beyond that are refused.
+func WithDeepMemory() Option {
+	return func(o *options) {
+		o.deepMemory = true
+	}
+}
+
+// defaultMaxFetchBytes is above the largest possible capture, 24M WORD samples
+const defaultMaxFetchBytes = 64 << 20
+
+// WithMaxFetchBytes limits how much a multi-chunk waveform read, such as
+// FetchFullWaveform, will pull into memory. A read that the preamble says
+// would be bigger fails before anything is fetched. The default is 64MB.
+func WithMaxFetchBytes(n int64) Option {
+	return func(o *options) {
+		o.maxFetchBytes = n
+	}
+}
+
+func (r *Rigol) applyOptions(opts []Option) options {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	r.logger = o.logger
+	r.writeDelay = o.writeDelay
+	r.byteOrder = o.byteOrder
+	r.stopBeforeRead = o.stopBeforeRead
+	r.checkErrors = o.checkErrors
+	r.compound = o.compound
+	r.onProgress = o.onProgress
+	r.maxFetchBytes = o.maxFetchBytes
+	r.deepMemory = o.deepMemory
+	r.caps = nil
+	if r.maxFetchBytes <= 0 {
+		r.maxFetchBytes = defaultMaxFetchBytes
+	}
+	r.history = nil
+	if o.history > 0 {
+		r.history = &history{entries: make([]Transaction, o.history)}
+	}
+	return o
+}
+
+// logf writes to the logger given by WithLogger, or the standard logger
+func (r *Rigol) logf(format string, args ...interface{}) {
+	if r.logger != nil {
+		r.logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
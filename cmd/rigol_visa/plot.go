@@ -0,0 +1,44 @@
+package main
+
+// PlotPoint is one column of a downsampled waveform, the range of voltages
+// seen in the samples from time T (relative to the trigger) until the next
+// column
+type PlotPoint struct {
+	T, Min, Max float64
+}
+
+// PlotPoints reduces BYTE format waveform data to at most width columns for
+// drawing, keeping the min and max voltage in each so glitches stay visible
+// however deep the capture. With fewer samples than columns each sample gets
+// its own column. Samples are converted one at a time, so a deep capture isn't
+// turned into a slice of float64s first.
+func (p *Preamble) PlotPoints(raw []byte, width int) []PlotPoint {
+	if width <= 0 || len(raw) == 0 {
+		return nil
+	}
+	if width > len(raw) {
+		width = len(raw)
+	}
+	points := make([]PlotPoint, width)
+	for col := range points {
+		first := col * len(raw) / width
+		last := (col + 1) * len(raw) / width
+		v := p.value(raw[first])
+		pt := PlotPoint{
+			T:   p.SampleTime(int64(first)),
+			Min: v,
+			Max: v,
+		}
+		for _, b := range raw[first+1 : last] {
+			x := p.value(b)
+			if x < pt.Min {
+				pt.Min = x
+			}
+			if x > pt.Max {
+				pt.Max = x
+			}
+		}
+		points[col] = pt
+	}
+	return points
+}
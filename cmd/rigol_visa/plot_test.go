@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestPlotPoints(t *testing.T) {
+	p := &Preamble{Xincrement: 1e-6, Yincrement: 1, Yref: 100}
+	raw := []byte{100, 90, 130, 100, 100, 100}
+	got := p.PlotPoints(raw, 2)
+	want := []PlotPoint{{T: 0, Min: -10, Max: 30}, {T: 3e-6, Min: 0, Max: 0}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("column %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	p.Inverted = true
+	if pt := p.PlotPoints(raw, 2)[0]; pt.Min != -30 || pt.Max != 10 {
+		t.Errorf("inverted: got %+v, want min -30 and max 10", pt)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// Pool shares a single Rigol between goroutines, such as HTTP handlers, by
+// running one caller's work at a time. A sequence of commands inside one Do
+// can't be interleaved with another caller's.
+type Pool struct {
+	mu     sync.Mutex
+	r      *Rigol
+	closed bool
+}
+
+// NewPool takes ownership of r, which should already be initialised
+func NewPool(r *Rigol) *Pool {
+	return &Pool{r: r}
+}
+
+// Do runs fn with exclusive use of the scope and returns its error
+func (p *Pool) Do(fn func(*Rigol) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errors.New("pool is closed")
+	}
+	return fn(p.r)
+}
+
+// Close waits for any running Do to finish, then closes the scope
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		p.r.Close()
+	}
+}
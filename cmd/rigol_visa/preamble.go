@@ -0,0 +1,362 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Preamble struct {
+	Format     int64   // 0 byte, 1 word, 2 asc
+	Type       int64   // 0 normal, 1 max, 2 raw
+	Points     int64   // number of points
+	Count      int64   // the number of averages in the average sample mode and 1 in other modes
+	Xincrement float64 // time diff between points
+	Xorigin    float64 // start time of waveform
+	Xref       int64   // Reference time of data point
+	Yincrement float64 // waveform increment in Y
+	Yorigin    int64   // vertical offset
+	Yref       int64   // vertical reference position
+
+	// Unit of the values from Volts. It isn't part of :WAV:PRE?, so it's looked
+	// up separately for channel and math sources. Empty means volts.
+	Unit Unit
+	// Inverted is set when the channel or math had :CHAN<n>:INV or :MATH:INV
+	// on, which flips the display but not the data, so Volts negates the values
+	// to match the display. Like Unit, it's looked up separately.
+	Inverted bool
+}
+
+// FetchPreamble reads the preamble of the current :WAV:SOUR, with Unit and
+// Inverted filled in if it's an analog channel or math
+func (r *Rigol) FetchPreamble() (*Preamble, error) {
+	source, err := r.Query(":WAV:SOUR?")
+	if err != nil {
+		return nil, err
+	}
+	return r.sourcePreamble(Source(source))
+}
+
+// sourcePreamble reads the preamble for source, which must be the current
+// :WAV:SOUR, and fills in its Unit and Inverted
+func (r *Rigol) sourcePreamble(source Source) (*Preamble, error) {
+	p, err := r.readPreamble()
+	if err != nil {
+		return nil, err
+	}
+	kind, ch, err := source.parse()
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "CHAN":
+		if p.Unit, err = r.ChannelUnit(ch); err != nil {
+			return nil, err
+		}
+		if p.Inverted, err = r.ChannelInverted(ch); err != nil {
+			return nil, err
+		}
+	case "MATH":
+		if p.Unit, err = r.mathUnit(); err != nil {
+			return nil, err
+		}
+		inv, err := r.Query(":MATH:INV?")
+		if err != nil {
+			return nil, err
+		}
+		p.Inverted = inv == "1"
+	}
+	return p, nil
+}
+
+// readPreamble reads :WAV:PRE? alone, for when only the point count matters
+func (r *Rigol) readPreamble() (*Preamble, error) {
+	err := r.Write(":WAV:PRE?")
+	if err != nil {
+		return nil, err
+	}
+	preamble, err := r.Read(100)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePreamble(strings.Split(string(preamble), "\n")[0])
+}
+
+// ParsePreamble parses the comma-separated preamble as returned by :WAV:PRE?
+// It does no I/O, so it can be used on saved preambles as well as live ones
+func ParsePreamble(s string) (*Preamble, error) {
+	p := &Preamble{}
+	parts := strings.Split(strings.TrimSpace(s), ",")
+	if len(parts) != 10 {
+		return nil, fmt.Errorf("malformed preamble %q: expected 10 fields, got %d", s, len(parts))
+	}
+	if pf, err := strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return nil, err
+	} else {
+		p.Format = pf
+	}
+	if pt, err := strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return nil, err
+	} else {
+		p.Type = pt
+	}
+	if pp, err := strconv.ParseInt(parts[2], 10, 64); err != nil {
+		return nil, err
+	} else {
+		p.Points = pp
+	}
+	if pc, err := strconv.ParseInt(parts[3], 10, 64); err != nil {
+		return nil, err
+	} else {
+		p.Count = pc
+	}
+	if pxi, err := strconv.ParseFloat(parts[4], 64); err != nil {
+		return nil, err
+	} else {
+		p.Xincrement = pxi
+	}
+	if pxo, err := strconv.ParseFloat(parts[5], 64); err != nil {
+		return nil, err
+	} else {
+		p.Xorigin = pxo
+	}
+	if pxr, err := strconv.ParseInt(parts[6], 10, 64); err != nil {
+		return nil, err
+	} else {
+		p.Xref = pxr
+	}
+	if pyi, err := strconv.ParseFloat(parts[7], 64); err != nil {
+		return nil, err
+	} else {
+		p.Yincrement = pyi
+	}
+	if pyo, err := strconv.ParseInt(parts[8], 10, 64); err != nil {
+		return nil, err
+	} else {
+		p.Yorigin = pyo
+	}
+	if pyr, err := strconv.ParseInt(parts[9], 10, 64); err != nil {
+		return nil, err
+	} else {
+		p.Yref = pyr
+	}
+
+	return p, nil
+}
+
+// String formats the preamble the same way the scope does, so it can be saved
+// and read back with ParsePreamble
+func (p *Preamble) String() string {
+	return fmt.Sprintf("%d,%d,%d,%d,%e,%e,%d,%e,%d,%d",
+		p.Format, p.Type, p.Points, p.Count,
+		p.Xincrement, p.Xorigin, p.Xref,
+		p.Yincrement, p.Yorigin, p.Yref)
+}
+
+// SampleInterval is the time between points, Xincrement
+func (p *Preamble) SampleInterval() (time.Duration, error) {
+	if err := p.checkXincrement(); err != nil {
+		return 0, err
+	}
+	return seconds(p.Xincrement), nil
+}
+
+// checkXincrement returns an error unless the time between points is usable,
+// as it's 0 in a zeroed or hand-built preamble and dividing by it gives Inf
+func (p *Preamble) checkXincrement() error {
+	if !(p.Xincrement > 0) || math.IsInf(p.Xincrement, 1) {
+		return fmt.Errorf("invalid preamble Xincrement %g", p.Xincrement)
+	}
+	return nil
+}
+
+// Duration is the time covered by all the points, Points * Xincrement
+func (p *Preamble) Duration() (time.Duration, error) {
+	if err := p.checkXincrement(); err != nil {
+		return 0, err
+	}
+	return seconds(float64(p.Points) * p.Xincrement), nil
+}
+
+// TriggerSampleIndex is the index (from 0) of the sample at the trigger, t=0.
+// It can be outside the data when the trigger is off screen.
+func (p *Preamble) TriggerSampleIndex() (int64, error) {
+	if err := p.checkXincrement(); err != nil {
+		return 0, err
+	}
+	return p.triggerSampleIndex(), nil
+}
+
+// triggerSampleIndex is TriggerSampleIndex for a preamble already checked
+func (p *Preamble) triggerSampleIndex() int64 {
+	return p.Xref + int64(math.Round(-p.Xorigin/p.Xincrement))
+}
+
+// SampleTime is the time of sample i (from 0) in seconds relative to the
+// trigger, negative before it, exactly as Xorigin gives it. The trigger usually
+// falls between two samples, so no sample is at exactly 0. It's called per
+// sample, so it doesn't check Xincrement, the exports do.
+func (p *Preamble) SampleTime(i int64) float64 {
+	return p.Xorigin + float64(i-p.Xref)*p.Xincrement
+}
+
+// TriggerTime is SampleTime snapped to the sample grid, so the sample at
+// TriggerSampleIndex is exactly 0. Times shift by up to half a sample. With a
+// zero Xincrement every time is 0.
+func (p *Preamble) TriggerTime(i int64) float64 {
+	if p.Xincrement == 0 {
+		return 0
+	}
+	return float64(i-p.triggerSampleIndex()) * p.Xincrement
+}
+
+// seconds converts s to a Duration, rounding to the nearest nanosecond
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
+
+// Volts converts BYTE format waveform data to voltages using the preamble's
+// vertical scaling, or amps or watts if that's the channel's Unit. Yincrement
+// is the actual volts per code, including any vernier (fine) scale and the
+// probe ratio, so a non-round value needs no further correction. The preamble
+// must be read after the scale was last changed, as the data is.
+func (p *Preamble) Volts(raw []byte) []float64 {
+	v := make([]float64, len(raw))
+	for i, b := range raw {
+		v[i] = p.value(b)
+	}
+	return v
+}
+
+// value converts one BYTE sample
+func (p *Preamble) value(b byte) float64 {
+	v := float64(int64(b)-p.Yorigin-p.Yref) * p.Yincrement
+	if p.Inverted {
+		return -v
+	}
+	return v
+}
+
+// SampleIterator steps through BYTE format waveform data one sample at a time,
+// returning its time as SampleTime and value as Volts would, without
+// converting the whole capture up front. ok is false once raw is used up.
+func (p *Preamble) SampleIterator(raw []byte) func() (t, v float64, ok bool) {
+	i := 0
+	return func() (float64, float64, bool) {
+		if i >= len(raw) {
+			return 0, 0, false
+		}
+		t := p.SampleTime(int64(i))
+		v := p.value(raw[i])
+		i++
+		return t, v, true
+	}
+}
+
+// InvertVolts negates voltages from Volts in place, for a probe connected the
+// wrong way round. The scope's own invert setting is handled by Inverted.
+func (p *Preamble) InvertVolts(v []float64) {
+	for i := range v {
+		v[i] = -v[i]
+	}
+}
+
+// SubtractBaseline removes a known DC offset from voltages in place
+func SubtractBaseline(v []float64, baseline float64) {
+	for i := range v {
+		v[i] -= baseline
+	}
+}
+
+// Dump writes each field with its meaning and units, for debugging
+func (p *Preamble) Dump(w io.Writer) {
+	formats := []string{"BYTE", "WORD", "ASC"}
+	types := []string{"NORM", "MAX", "RAW"}
+	name := func(names []string, v int64) string {
+		if v >= 0 && v < int64(len(names)) {
+			return names[v]
+		}
+		return fmt.Sprintf("unknown (%d)", v)
+	}
+	fmt.Fprintf(w, "Format: %s\n", name(formats, p.Format))
+	fmt.Fprintf(w, "Type: %s\n", name(types, p.Type))
+	fmt.Fprintf(w, "Points: %d\n", p.Points)
+	fmt.Fprintf(w, "Count: %d (averages)\n", p.Count)
+	fmt.Fprintf(w, "Xincrement: %ss/sample\n", siPrefix(p.Xincrement))
+	fmt.Fprintf(w, "Xorigin: %ss (time of first sample)\n", siPrefix(p.Xorigin))
+	fmt.Fprintf(w, "Xref: %d (reference sample)\n", p.Xref)
+	fmt.Fprintf(w, "Yincrement: %s%s/step\n", siPrefix(p.Yincrement), p.Unit.Symbol())
+	fmt.Fprintf(w, "Yorigin: %d (vertical offset, steps)\n", p.Yorigin)
+	fmt.Fprintf(w, "Yref: %d (vertical reference, steps)\n", p.Yref)
+}
+
+// siPrefix formats v with an SI prefix, e.g. 2e-9 as "2n"
+func siPrefix(v float64) string {
+	prefixes := []struct {
+		scale  float64
+		prefix string
+	}{
+		{1e9, "G"}, {1e6, "M"}, {1e3, "k"}, {1, ""},
+		{1e-3, "m"}, {1e-6, "u"}, {1e-9, "n"}, {1e-12, "p"},
+	}
+	for _, p := range prefixes {
+		if math.Abs(v) >= p.scale {
+			return strconv.FormatFloat(v/p.scale, 'g', 6, 64) + p.prefix
+		}
+	}
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+// Resample linearly interpolates v, sampled every fromXinc seconds, to one
+// sample every toXinc seconds over the same time span
+func Resample(v []float64, fromXinc, toXinc float64) ([]float64, error) {
+	if !(fromXinc > 0) || !(toXinc > 0) || math.IsInf(fromXinc, 1) || math.IsInf(toXinc, 1) {
+		return nil, fmt.Errorf("invalid sample intervals %g and %g", fromXinc, toXinc)
+	}
+	if len(v) == 0 {
+		return nil, nil
+	}
+	span := float64(len(v)-1) * fromXinc
+	out := make([]float64, int(math.Floor(span/toXinc))+1)
+	for i := range out {
+		pos := float64(i) * toXinc / fromXinc
+		j := int(pos)
+		if j >= len(v)-1 {
+			out[i] = v[len(v)-1]
+			continue
+		}
+		frac := pos - float64(j)
+		out[i] = v[j] + (v[j+1]-v[j])*frac
+	}
+	return out, nil
+}
+
+// AlignOnTrigger works out how many samples to drop from the start of a and b
+// so that a[ashift:] and b[bshift:] start at the same time relative to their
+// triggers. Each shift is in its own capture's samples, so the captures can
+// have different sample rates.
+func AlignOnTrigger(a, b []byte, pa, pb *Preamble) (ashift, bshift int, err error) {
+	if err := pa.checkXincrement(); err != nil {
+		return 0, 0, err
+	}
+	if err := pb.checkXincrement(); err != nil {
+		return 0, 0, err
+	}
+	// time of the first sample relative to the trigger
+	startA := pa.Xorigin - float64(pa.Xref)*pa.Xincrement
+	startB := pb.Xorigin - float64(pb.Xref)*pb.Xincrement
+	start := math.Max(startA, startB)
+
+	ashift = int(math.Round((start - startA) / pa.Xincrement))
+	bshift = int(math.Round((start - startB) / pb.Xincrement))
+	if ashift > len(a) {
+		ashift = len(a)
+	}
+	if bshift > len(b) {
+		bshift = len(b)
+	}
+	return ashift, bshift, nil
+}
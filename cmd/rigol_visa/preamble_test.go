@@ -0,0 +1,183 @@
+package main
+
+import (
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePreamble(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Preamble
+		err  string
+	}{
+		{
+			name: "raw byte",
+			in:   "0,2,1200000,1,2.000000e-09,-1.200000e-03,0,4.000000e-02,0,127",
+			want: Preamble{Format: 0, Type: 2, Points: 1200000, Count: 1, Xincrement: 2e-9, Xorigin: -1.2e-3, Yincrement: 0.04, Yref: 127},
+		},
+		{
+			name: "trailing newline",
+			in:   "1,0,1200,1,1.000000e-06,-6.000000e-04,0,8.000000e-03,-25,32768\n",
+			want: Preamble{Format: 1, Points: 1200, Count: 1, Xincrement: 1e-6, Xorigin: -6e-4, Yincrement: 8e-3, Yorigin: -25, Yref: 32768},
+		},
+		{name: "empty", in: "", err: "expected 10 fields, got 1"},
+		{name: "short", in: "0,2,1200,1,2e-09,0,0,0.04,0", err: "expected 10 fields, got 9"},
+		{name: "long", in: "0,2,1200,1,2e-09,0,0,0.04,0,127,5", err: "expected 10 fields, got 11"},
+		{name: "bad int", in: "0,2,lots,1,2e-09,0,0,0.04,0,127", err: "invalid syntax"},
+		{name: "bad float", in: "0,2,1200,1,2e-09,zero,0,0.04,0,127", err: "invalid syntax"},
+		{name: "garbled", in: "#9000001200\x00\x01", err: "expected 10 fields"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := ParsePreamble(tt.in)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *p != tt.want {
+				t.Errorf("got %+v, want %+v", *p, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreambleStringRoundTrip(t *testing.T) {
+	p := &Preamble{Format: 1, Type: 2, Points: 24000000, Count: 1, Xincrement: 4e-9, Xorigin: -0.048, Xref: 0, Yincrement: 0.0123, Yorigin: -12, Yref: 32768}
+	got, err := ParsePreamble(p.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *p {
+		t.Errorf("got %+v, want %+v", *got, *p)
+	}
+}
+
+func TestAlignOnTrigger(t *testing.T) {
+	a := make([]byte, 100)
+	b := make([]byte, 100)
+	pa := &Preamble{Xincrement: 1e-6, Xorigin: -50e-6}
+	pb := &Preamble{Xincrement: 2e-6, Xorigin: -40e-6}
+	ashift, bshift, err := AlignOnTrigger(a, b, pa, pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ashift != 10 || bshift != 0 {
+		t.Errorf("got shifts %d and %d, want 10 and 0", ashift, bshift)
+	}
+	if _, _, err := AlignOnTrigger(a, b, pa, &Preamble{}); err == nil {
+		t.Error("expected an error for a zero Xincrement")
+	}
+}
+
+func TestResample(t *testing.T) {
+	got, err := Resample([]float64{0, 1, 2}, 2e-6, 1e-6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{0, 0.5, 1, 1.5, 2}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+	if _, err := Resample([]float64{0, 1}, 0, 1e-6); err == nil {
+		t.Error("expected an error for a zero interval")
+	}
+}
+
+func TestSampleIntervalAndDuration(t *testing.T) {
+	p := &Preamble{Points: 1200, Xincrement: 2e-6}
+	interval, err := p.SampleInterval()
+	if err != nil || interval != 2*time.Microsecond {
+		t.Errorf("SampleInterval = %v, %v, want 2us", interval, err)
+	}
+	d, err := p.Duration()
+	if err != nil || d != 2400*time.Microsecond {
+		t.Errorf("Duration = %v, %v, want 2.4ms", d, err)
+	}
+	zero := &Preamble{Points: 1200}
+	if _, err := zero.SampleInterval(); err == nil {
+		t.Error("SampleInterval: expected an error for a zero Xincrement")
+	}
+	if _, err := zero.Duration(); err == nil {
+		t.Error("Duration: expected an error for a zero Xincrement")
+	}
+}
+
+func TestVoltsVernier(t *testing.T) {
+	// a vernier scale gives a Yincrement that isn't a round fraction of a
+	// 1-2-5 step, which Volts has to use as is
+	p := &Preamble{Yincrement: 0.0123, Yorigin: -10, Yref: 127}
+	raw := []byte{0, 117, 127, 200, 255}
+	want := []float64{-117 * 0.0123, 0, 10 * 0.0123, 83 * 0.0123, 138 * 0.0123}
+	got := p.Volts(raw)
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-12 {
+			t.Errorf("code %d: got %g, want %g", raw[i], got[i], want[i])
+		}
+	}
+
+	p.Inverted = true
+	if got := p.Volts([]byte{200}); math.Abs(got[0]+83*0.0123) > 1e-12 {
+		t.Errorf("inverted: got %g, want %g", got[0], -83*0.0123)
+	}
+}
+
+func TestSampleTime(t *testing.T) {
+	// trigger 0.3 of a sample after sample 10
+	p := &Preamble{Xincrement: 1e-6, Xorigin: -10.3e-6}
+	if got := p.SampleTime(10); math.Abs(got-(-0.3e-6)) > 1e-15 {
+		t.Errorf("SampleTime(10) = %g, want -0.3e-6", got)
+	}
+	if got := p.TriggerTime(10); got != 0 {
+		t.Errorf("TriggerTime(10) = %g, want 0", got)
+	}
+	if i, err := p.TriggerSampleIndex(); err != nil || i != 10 {
+		t.Errorf("TriggerSampleIndex = %d, %v, want 10", i, err)
+	}
+	if _, err := (&Preamble{Xorigin: -1}).TriggerSampleIndex(); err == nil {
+		t.Error("TriggerSampleIndex: expected an error for a zero Xincrement")
+	}
+	next := p.SampleIterator([]byte{0})
+	if tm, _, _ := next(); tm != p.SampleTime(0) {
+		t.Errorf("SampleIterator time %g, want SampleTime(0) = %g", tm, p.SampleTime(0))
+	}
+}
+
+func TestExportGnuplotTriggerAtZero(t *testing.T) {
+	// trigger between samples 2 and 3, nearer 3
+	p := &Preamble{Xincrement: 0.5, Xorigin: -1.25, Yincrement: 1}
+	raw := []byte{0, 0, 0}
+	for _, tt := range []struct {
+		opts []ExportOption
+		want string
+	}{
+		{nil, "-1.25 0\n-0.75 0\n-0.25 0\n"},
+		{[]ExportOption{WithTriggerAtZero()}, "-1.5 0\n-1 0\n-0.5 0\n"},
+	} {
+		var buf strings.Builder
+		if err := ExportGnuplot(&buf, p, raw, tt.opts...); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		if got := out[strings.LastIndex(out, "#"):]; !strings.HasSuffix(got, tt.want) {
+			t.Errorf("got data\n%s\nwant\n%s", got, tt.want)
+		}
+	}
+	if err := ExportGnuplot(io.Discard, &Preamble{Yincrement: 1}, raw); err == nil {
+		t.Error("expected an error exporting with a zero Xincrement")
+	}
+}
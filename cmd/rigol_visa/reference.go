@@ -0,0 +1,46 @@
+package main
+
+import "fmt"
+
+func checkReference(ref int) error {
+	if ref < 1 || ref > 10 {
+		return fmt.Errorf("invalid reference %d, expected 1-10", ref)
+	}
+	return nil
+}
+
+// SaveToReference stores the current waveform of source in reference slot ref
+// (1-10) and shows it. Only analog channels and math can be saved, not LA
+// channels.
+func (r *Rigol) SaveToReference(source Source, ref int) error {
+	if err := checkReference(ref); err != nil {
+		return err
+	}
+	kind, _, err := source.parse()
+	if err != nil {
+		return err
+	}
+	if kind == "D" {
+		return fmt.Errorf("LA channel %s can't be saved to a reference", source)
+	}
+	setup := []string{
+		":REF:DISP ON",
+		cmd(fmt.Sprintf(":REF%d:ENAB", ref), true),
+		cmd(":REF:CURR", fmt.Sprintf("REF%d", ref)),
+		cmd(":REF:SOUR", source),
+		":REF:SAVE",
+	}
+	return r.WriteAll(setup)
+}
+
+// DisplayReference shows or hides reference slot ref (1-10)
+func (r *Rigol) DisplayReference(ref int, on bool) error {
+	if err := checkReference(ref); err != nil {
+		return err
+	}
+	setup := []string{cmd(fmt.Sprintf(":REF%d:ENAB", ref), on)}
+	if on {
+		setup = append([]string{":REF:DISP ON"}, setup...)
+	}
+	return r.WriteAll(setup)
+}
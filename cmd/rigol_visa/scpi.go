@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cmd builds the SCPI command root followed by args, comma separated. bools are
+// sent as ON/OFF and floats in the shortest form that round trips, e.g.
+// cmd(":TRIG:EDG:LEV", 1.5) is ":TRIG:EDG:LEV 1.5".
+func cmd(root string, args ...interface{}) string {
+	if len(args) == 0 {
+		return root
+	}
+	params := make([]string, len(args))
+	for i, a := range args {
+		switch v := a.(type) {
+		case bool:
+			params[i] = onOff(v)
+		case float32, float64:
+			params[i] = fmt.Sprintf("%g", v)
+		default:
+			params[i] = fmt.Sprint(v)
+		}
+	}
+	return root + " " + strings.Join(params, ",")
+}
@@ -0,0 +1,38 @@
+package main
+
+import "fmt"
+
+// ScreenOptions selects how CaptureScreen renders the display
+type ScreenOptions struct {
+	Grayscale bool   // grey levels instead of colour
+	Invert    bool   // white background, for printing
+	Format    string // BMP24, BMP8, PNG, JPEG or TIFF, PNG if empty
+}
+
+// CaptureScreen returns an image of the scope's display
+func (r *Rigol) CaptureScreen(opts ScreenOptions) ([]byte, error) {
+	format := opts.Format
+	switch format {
+	case "":
+		format = "PNG"
+	case "BMP24", "BMP8", "PNG", "JPEG", "TIFF":
+	default:
+		return nil, fmt.Errorf("invalid screen capture format %q", format)
+	}
+	if err := r.Write(cmd(":DISP:DATA?", !opts.Grayscale, opts.Invert, format)); err != nil {
+		return nil, err
+	}
+	// big enough for an uncompressed 800x480 24 bit BMP plus the TMC header
+	d, err := r.readBinary(800*480*3 + 1024)
+	if err != nil {
+		return nil, err
+	}
+	_, img, err := splitTMCBlock(d)
+	return img, err
+}
+
+// ClearDisplay clears all waveforms from the screen, including persistence,
+// e.g. before CaptureScreen. If the scope is running it redraws straight away.
+func (r *Rigol) ClearDisplay() error {
+	return r.Write(":CLE")
+}
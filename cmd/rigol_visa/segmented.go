@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ConfigureSegmented turns on waveform recording and starts it for the given
+// number of frames, each frame being one triggered acquisition
+func (r *Rigol) ConfigureSegmented(frames int) error {
+	if frames < 1 {
+		return fmt.Errorf("invalid frame count %d", frames)
+	}
+	if err := r.Write(":FUNC:WREC:ENAB ON"); err != nil {
+		return err
+	}
+	max, err := r.Query(":FUNC:WREC:FMAX?")
+	if err != nil {
+		return err
+	}
+	if m, err := strconv.Atoi(max); err == nil && frames > m {
+		return fmt.Errorf("%d frames requested but at most %d fit at the current memory depth", frames, m)
+	}
+	setup := []string{
+		cmd(":FUNC:WREC:FEND", frames), // last frame to record
+		":FUNC:WREC:OPER RUN",          // start recording
+	}
+	return r.WriteAll(setup)
+}
+
+// FetchSegment reads the data for one recorded frame (from 1) of source.
+// Recording must have finished.
+func (r *Rigol) FetchSegment(frame int, source Source) ([]byte, error) {
+	if frame < 1 {
+		return nil, fmt.Errorf("invalid frame %d", frame)
+	}
+	if err := r.Write(cmd(":FUNC:WREP:FCUR", frame)); err != nil {
+		return nil, err
+	}
+	if err := r.selectSource(source); err != nil {
+		return nil, err
+	}
+	_, data, err := r.fetchWindow(1, maxPointsPerRead)
+	return data, err
+}
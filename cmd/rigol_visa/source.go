@@ -0,0 +1,70 @@
+package main
+
+import "fmt"
+
+// Source is a waveform source as used by :WAV:SOUR, build them with SourceCH,
+// SourceDigital or SourceMath rather than by hand
+type Source string
+
+// SourceMath is the math channel
+const SourceMath Source = "MATH"
+
+// SourceCH is analog channel n (1-4)
+func SourceCH(n int) Source {
+	return Source(fmt.Sprintf("CHAN%d", n))
+}
+
+// SourceDigital is logic analyser channel n (0-15)
+func SourceDigital(n int) Source {
+	return Source(fmt.Sprintf("D%d", n))
+}
+
+// parse splits s into its kind (CHAN, D or MATH) and channel number
+func (s Source) parse() (string, int, error) {
+	var n int
+	switch {
+	case s == SourceMath:
+		return "MATH", 0, nil
+	case len(s) > 4 && s[:4] == "CHAN":
+		if _, err := fmt.Sscanf(string(s), "CHAN%d", &n); err == nil && n >= 1 && n <= 4 {
+			return "CHAN", n, nil
+		}
+	case len(s) > 1 && s[0] == 'D':
+		if _, err := fmt.Sscanf(string(s), "D%d", &n); err == nil && n >= 0 && n <= 15 {
+			return "D", n, nil
+		}
+	}
+	return "", 0, fmt.Errorf("invalid waveform source %q, expected CHAN1-4, D0-15 or MATH", string(s))
+}
+
+// Validate checks s is a source the DS1000Z/MSO1000Z family has. Whether the
+// connected model has it is checked by Capabilities.CheckSource.
+func (s Source) Validate() error {
+	_, _, err := s.parse()
+	return err
+}
+
+// CheckSource checks s is a source this model has, returning ErrUnsupported
+// for an analog channel beyond MaxChannels or an LA channel without the LA
+func (c Capabilities) CheckSource(s Source) error {
+	kind, n, err := s.parse()
+	if err != nil {
+		return err
+	}
+	switch {
+	case kind == "CHAN" && n > c.MaxChannels:
+		return fmt.Errorf("source %s on %s: %w", s, c.Model, ErrUnsupported)
+	case kind == "D" && !c.HasLA:
+		return fmt.Errorf("source %s on %s: %w", s, c.Model, ErrUnsupported)
+	}
+	return nil
+}
+
+// checkSource is Capabilities.CheckSource for the connected scope
+func (r *Rigol) checkSource(s Source) error {
+	caps, err := r.Capabilities()
+	if err != nil {
+		return err
+	}
+	return caps.CheckSource(s)
+}
@@ -0,0 +1,47 @@
+package main
+
+// ClippedSamples counts BYTE samples sitting at the bottom (0x00) and top
+// (0xff) rails, where the signal has gone off the vertical range
+func (p *Preamble) ClippedSamples(raw []byte) (low, high int) {
+	for _, b := range raw {
+		switch b {
+		case 0x00:
+			low++
+		case 0xff:
+			high++
+		}
+	}
+	return low, high
+}
+
+// Stats summarises a waveform in volts
+type Stats struct {
+	Min, Max, Mean, Vpp float64
+
+	// Clipped is set when any samples hit the rails, in which case Min, Max
+	// and Vpp only give a bound on the real signal
+	Clipped                 bool
+	ClippedLow, ClippedHigh int
+}
+
+// Stats works out the summary for BYTE format waveform data
+func (p *Preamble) Stats(raw []byte) Stats {
+	var s Stats
+	v := p.Volts(raw)
+	for i, x := range v {
+		if i == 0 || x < s.Min {
+			s.Min = x
+		}
+		if i == 0 || x > s.Max {
+			s.Max = x
+		}
+		s.Mean += x
+	}
+	if len(v) > 0 {
+		s.Mean /= float64(len(v))
+	}
+	s.Vpp = s.Max - s.Min
+	s.ClippedLow, s.ClippedHigh = p.ClippedSamples(raw)
+	s.Clipped = s.ClippedLow > 0 || s.ClippedHigh > 0
+	return s
+}
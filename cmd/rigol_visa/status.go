@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StatusByteRegister is the IEEE 488.2 status byte from *STB?
+type StatusByteRegister byte
+
+// ErrorAvailable is set when the error queue is not empty
+func (s StatusByteRegister) ErrorAvailable() bool { return s&(1<<2) != 0 }
+
+// MessageAvailable is set when there is output waiting to be read
+func (s StatusByteRegister) MessageAvailable() bool { return s&(1<<4) != 0 }
+
+// EventStatus is set when an enabled bit in the event status register is set
+func (s StatusByteRegister) EventStatus() bool { return s&(1<<5) != 0 }
+
+// ServiceRequest is the master summary / request service bit
+func (s StatusByteRegister) ServiceRequest() bool { return s&(1<<6) != 0 }
+
+// EventStatusRegister is the IEEE 488.2 standard event status register from
+// *ESR?. Reading it clears it.
+type EventStatusRegister byte
+
+// OperationComplete is set by *OPC once all pending operations have finished
+func (e EventStatusRegister) OperationComplete() bool { return e&(1<<0) != 0 }
+
+// QueryError is set when output was lost or a query had nothing to return
+func (e EventStatusRegister) QueryError() bool { return e&(1<<2) != 0 }
+
+// DeviceError is set for a device specific error
+func (e EventStatusRegister) DeviceError() bool { return e&(1<<3) != 0 }
+
+// ExecutionError is set when a command was valid but couldn't be carried out,
+// e.g. a parameter out of range
+func (e EventStatusRegister) ExecutionError() bool { return e&(1<<4) != 0 }
+
+// CommandError is set when a command couldn't be parsed
+func (e EventStatusRegister) CommandError() bool { return e&(1<<5) != 0 }
+
+// PowerOn is set when the scope has been power cycled since the last read
+func (e EventStatusRegister) PowerOn() bool { return e&(1<<7) != 0 }
+
+// Failed reports whether any of the error bits are set
+func (e EventStatusRegister) Failed() bool {
+	return e.QueryError() || e.DeviceError() || e.ExecutionError() || e.CommandError()
+}
+
+// StatusByte reads the status byte
+func (r *Rigol) StatusByte() (StatusByteRegister, error) {
+	v, err := r.queryRegister("*STB?")
+	return StatusByteRegister(v), err
+}
+
+// EventStatus reads, and so clears, the standard event status register
+func (r *Rigol) EventStatus() (EventStatusRegister, error) {
+	v, err := r.queryRegister("*ESR?")
+	return EventStatusRegister(v), err
+}
+
+func (r *Rigol) queryRegister(query string) (byte, error) {
+	resp, err := r.Query(query)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.ParseUint(resp, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected response %q to %s", resp, query)
+	}
+	return byte(v), nil
+}
+
+// maxErrorQueue is more than the scope's error queue holds, so a bad response
+// can't keep ErrorQueue reading forever
+const maxErrorQueue = 50
+
+// ErrorQueue reads and empties the scope's error queue, returning each entry
+// as reported, e.g. -113,"Undefined header"
+func (r *Rigol) ErrorQueue() ([]string, error) {
+	var errs []string
+	for i := 0; i < maxErrorQueue; i++ {
+		resp, err := r.Query(":SYST:ERR?")
+		if err != nil {
+			return errs, err
+		}
+		code := strings.SplitN(resp, ",", 2)[0]
+		if n, err := strconv.Atoi(strings.TrimSpace(code)); err == nil && n == 0 {
+			break
+		}
+		errs = append(errs, resp)
+	}
+	return errs, nil
+}
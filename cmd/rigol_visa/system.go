@@ -0,0 +1,37 @@
+package main
+
+// onOff is the SCPI boolean for b
+func onOff(b bool) string {
+	if b {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// SetFrontPanelLock locks or unlocks the front panel keys, so settings can't be
+// changed by hand during unattended captures
+func (r *Rigol) SetFrontPanelLock(locked bool) error {
+	return r.Write(cmd(":SYST:LOCK", locked))
+}
+
+// SetBeeper turns the key and alert beeper on or off
+func (r *Rigol) SetBeeper(on bool) error {
+	return r.Write(cmd(":SYST:BEEP", on))
+}
+
+// Shutdown leaves the scope in a usable state and closes the connection: it
+// stops acquisition, unlocks the front panel and clears the status registers
+// and error queue. The connection is closed even if one of those fails, and
+// the first error is returned.
+func (r *Rigol) Shutdown() error {
+	var firstErr error
+	for _, c := range []string{":STOP", cmd(":SYST:LOCK", false), "*CLS"} {
+		if err := r.Write(c); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := r.Transport.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
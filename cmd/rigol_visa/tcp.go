@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"time"
+)
+
+// TCPTransport talks plain SCPI over the scope's raw socket on port 5555.
+// Commands are newline terminated. Responses are either a newline terminated
+// line or a TMC block (#<n><length><data>\n) which is read by its length, as
+// the data may itself contain newlines.
+type TCPTransport struct {
+	Timeout time.Duration // applied to each Write and Read, 0 for none
+
+	conn      net.Conn
+	rd        *bufio.Reader
+	remaining int  // bytes of the current block response not yet read
+	inLine    bool // the last Read stopped part way through a line
+}
+
+// DialTCP connects to host, adding the default port 5555 if none is given
+func DialTCP(host string) (*TCPTransport, error) {
+	return DialTCPTimeout(host, 10*time.Second)
+}
+
+// DialTCPTimeout is DialTCP with timeout used for the connection as well as
+// each Write and Read. A zero timeout means none, as for net.DialTimeout.
+func DialTCPTimeout(host string, timeout time.Duration) (*TCPTransport, error) {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "5555")
+	}
+	t := &TCPTransport{Timeout: timeout}
+	conn, err := net.DialTimeout("tcp", host, t.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to %s: %v", host, err)
+	}
+	t.conn = conn
+	t.rd = bufio.NewReader(conn)
+	return t, nil
+}
+
+// deadline is when the next Write or Read times out, or the zero time (no
+// deadline) if Timeout is 0
+func (t *TCPTransport) deadline() time.Time {
+	if t.Timeout == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(t.Timeout)
+}
+
+func (t *TCPTransport) Close() error {
+	return t.conn.Close()
+}
+
+func (t *TCPTransport) Write(b []byte) error {
+	if len(b) == 0 || b[len(b)-1] != '\n' {
+		b = append(b, '\n')
+	}
+	t.conn.SetWriteDeadline(t.deadline())
+	if _, err := t.conn.Write(b); err != nil {
+		return fmt.Errorf("error writing to the device: %v", err)
+	}
+	return nil
+}
+
+// Read returns at most n bytes of the response. Any part of the response
+// beyond n, line or block, is returned by the following Reads.
+func (t *TCPTransport) Read(n uint32) ([]byte, error) {
+	t.conn.SetReadDeadline(t.deadline())
+	if t.remaining > 0 {
+		return t.readBlock(n)
+	}
+	if t.inLine {
+		return t.readLine(n)
+	}
+
+	first, err := t.rd.Peek(1)
+	if err != nil {
+		return nil, fmt.Errorf("read failed: %v", err)
+	}
+	if first[0] != '#' {
+		return t.readLine(n)
+	}
+
+	// #, then a digit giving the number of length digits that follow
+	head, err := t.rd.Peek(2)
+	if err != nil {
+		return nil, fmt.Errorf("read failed: %v", err)
+	}
+	digits := int(head[1] - '0')
+	if digits < 1 || digits > 9 {
+		return nil, fmt.Errorf("invalid block header %q", head)
+	}
+	head, err = t.rd.Peek(2 + digits)
+	if err != nil {
+		return nil, fmt.Errorf("read failed: %v", err)
+	}
+	length, err := strconv.Atoi(string(head[2:]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid block header %q", head)
+	}
+	t.remaining = len(head) + length + 1 // header, data and the trailing newline
+	return t.readBlock(n)
+}
+
+// ReadAll returns the whole of the response, a line of any length or the rest
+// of a block
+func (t *TCPTransport) ReadAll() ([]byte, error) {
+	t.conn.SetReadDeadline(t.deadline())
+	if t.remaining > 0 {
+		return t.readBlock(math.MaxUint32)
+	}
+	if t.inLine {
+		return t.readLine(math.MaxUint32)
+	}
+	first, err := t.rd.Peek(1)
+	if err != nil {
+		return nil, fmt.Errorf("read failed: %v", err)
+	}
+	if first[0] == '#' {
+		return t.Read(math.MaxUint32)
+	}
+	return t.readLine(math.MaxUint32)
+}
+
+// readLine reads up to the end of the line, or n bytes of it. The rest of a
+// longer line stays buffered for the next Read.
+func (t *TCPTransport) readLine(n uint32) ([]byte, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	var line []byte
+	for uint32(len(line)) < n {
+		c, err := t.rd.ReadByte()
+		if err != nil {
+			t.inLine = false
+			return nil, fmt.Errorf("read failed: %v", err)
+		}
+		line = append(line, c)
+		if c == '\n' {
+			t.inLine = false
+			return line, nil
+		}
+	}
+	t.inLine = true
+	return line, nil
+}
+
+func (t *TCPTransport) readBlock(n uint32) ([]byte, error) {
+	size := t.remaining
+	if uint32(size) > n {
+		size = int(n)
+	}
+	b := make([]byte, size)
+	read, err := io.ReadFull(t.rd, b)
+	t.remaining -= read
+	if err != nil {
+		t.remaining = 0
+		return nil, fmt.Errorf("read failed after %d bytes: %v", read, err)
+	}
+	return b, nil
+}
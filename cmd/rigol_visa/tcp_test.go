@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPReadLongLine(t *testing.T) {
+	client, scope := net.Pipe()
+	defer client.Close()
+	defer scope.Close()
+	go scope.Write([]byte("RIGOL TECHNOLOGIES,#1 long\n#15ab\ncd\nOK\n"))
+
+	tr := &TCPTransport{Timeout: time.Second, conn: client, rd: bufio.NewReader(client)}
+	for _, want := range []string{"RIGOL TECH", "NOLOGIES,#", "1 long\n", "#15ab\ncd", "\n", "OK\n"} {
+		got, err := tr.Read(uint32(len(want)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestTCPZeroTimeout(t *testing.T) {
+	client, scope := net.Pipe()
+	defer client.Close()
+	defer scope.Close()
+	go func() {
+		buf := make([]byte, 64)
+		n, _ := scope.Read(buf)
+		if string(buf[:n]) == "*IDN?\n" {
+			scope.Write([]byte("RIGOL TECHNOLOGIES\n"))
+		}
+	}()
+
+	// a zero Timeout is no deadline, not one that has already passed
+	tr := &TCPTransport{conn: client, rd: bufio.NewReader(client)}
+	if err := tr.Write([]byte("*IDN?")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tr.Read(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "RIGOL TECHNOLOGIES\n" {
+		t.Errorf("got %q", got)
+	}
+}
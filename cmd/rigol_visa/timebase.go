@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// TriggerOffset returns the index (from 0) of the trigger point within the
+// acquisition memory. With no horizontal offset the trigger is in the middle
+// of memory, a positive :TIM:MAIN:OFFS moves it earlier.
+func (r *Rigol) TriggerOffset() (int64, error) {
+	offset, err := r.HorizontalPosition()
+	if err != nil {
+		return 0, err
+	}
+	srate, err := r.QueryFloat(":ACQ:SRAT?")
+	if err != nil {
+		return 0, err
+	}
+	depth, err := r.memoryDepth(srate)
+	if err != nil {
+		return 0, err
+	}
+	return int64(math.Round(depth/2 - offset*srate)), nil
+}
+
+// HorizontalPosition returns the main timebase offset, :TIM:MAIN:OFFS?, in
+// seconds: how far the trigger is moved left of the centre of the screen. It's
+// already reflected in a preamble's Xorigin, which SampleTime uses, so this is
+// for recording the setup alongside exports or lining up captures from
+// scopes set up differently.
+func (r *Rigol) HorizontalPosition() (float64, error) {
+	return r.QueryFloat(":TIM:MAIN:OFFS?")
+}
+
+// memoryDepth returns :ACQ:MDEP?, working it out from the sample rate and the
+// 12 division screen width when it is AUTO
+func (r *Rigol) memoryDepth(srate float64) (float64, error) {
+	mdep, err := r.Query(":ACQ:MDEP?")
+	if err != nil {
+		return 0, err
+	}
+	if mdep != "AUTO" {
+		depth, err := strconv.ParseFloat(mdep, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected memory depth %q", mdep)
+		}
+		return depth, nil
+	}
+	scale, err := r.QueryFloat(":TIM:MAIN:SCAL?")
+	if err != nil {
+		return 0, err
+	}
+	return srate * scale * 12, nil
+}
+
+// TimebaseMode returns the horizontal mode, MAIN, XY or ROLL. Waveform data
+// from XY or ROLL mode doesn't map to time the way MAIN does.
+func (r *Rigol) TimebaseMode() (string, error) {
+	return r.Query(":TIM:MODE?")
+}
+
+// SetTimebaseMode sets the horizontal mode to MAIN, XY or ROLL
+func (r *Rigol) SetTimebaseMode(mode string) error {
+	switch mode {
+	case "MAIN", "XY", "ROLL":
+	default:
+		return fmt.Errorf("invalid timebase mode %q, expected MAIN, XY or ROLL", mode)
+	}
+	return r.Write(cmd(":TIM:MODE", mode))
+}
+
+// EnableDelayedTimebase turns the delayed (zoomed) sweep window on or off
+func (r *Rigol) EnableDelayedTimebase(on bool) error {
+	return r.Write(cmd(":TIM:DEL:ENAB", on))
+}
+
+// SetDelayedTimebase sets the delayed sweep to scale seconds per division,
+// centred offset seconds from the trigger. The scope limits scale to at most
+// the main timebase scale, and keeps the window within the main sweep.
+func (r *Rigol) SetDelayedTimebase(scale, offset float64) error {
+	if scale <= 0 {
+		return fmt.Errorf("invalid delayed timebase scale %g", scale)
+	}
+	return r.WriteAll([]string{
+		cmd(":TIM:DEL:SCAL", scale),
+		cmd(":TIM:DEL:OFFS", offset),
+	})
+}
+
+// DelayedTimebase returns the delayed sweep's scale in seconds per division and
+// its offset in seconds
+func (r *Rigol) DelayedTimebase() (scale, offset float64, err error) {
+	if scale, err = r.QueryFloat(":TIM:DEL:SCAL?"); err != nil {
+		return 0, 0, err
+	}
+	if offset, err = r.QueryFloat(":TIM:DEL:OFFS?"); err != nil {
+		return 0, 0, err
+	}
+	return scale, offset, nil
+}
+
+// Timebase returns the main timebase scale in seconds per division
+func (r *Rigol) Timebase() (float64, error) {
+	return r.QueryFloat(":TIM:MAIN:SCAL?")
+}
+
+// SetTimebase sets the main timebase scale to scale seconds per division and
+// returns the scale actually applied, which the scope rounds to the nearest
+// step it supports
+func (r *Rigol) SetTimebase(scale float64) (float64, error) {
+	if scale <= 0 {
+		return 0, fmt.Errorf("invalid timebase scale %g", scale)
+	}
+	if err := r.Write(cmd(":TIM:MAIN:SCAL", scale)); err != nil {
+		return 0, err
+	}
+	return r.Timebase()
+}
@@ -0,0 +1,60 @@
+package main
+
+// Transport carries commands to the scope and responses back from it
+type Transport interface {
+	// Write sends a single command
+	Write(b []byte) error
+	// Read returns up to n bytes of the response
+	Read(n uint32) ([]byte, error)
+	Close() error
+}
+
+// termCharTransport is implemented by transports whose reads can stop early at
+// a termination character, which has to be turned off for binary data
+type termCharTransport interface {
+	TermCharEnabled() (bool, error)
+	SetTermCharEnabled(on bool) error
+}
+
+// readAllTransport is implemented by transports that can tell where a response
+// ends, so it can be read without knowing its length in advance
+type readAllTransport interface {
+	ReadAll() ([]byte, error)
+}
+
+// readAllChunk is how much ReadAll asks the transport for at a time
+const readAllChunk = 64 * 1024
+
+// TermCharEnabled reports whether reads stop at the termination character.
+// Always false for transports that read by message length.
+func (r *Rigol) TermCharEnabled() (bool, error) {
+	if t, ok := r.Transport.(termCharTransport); ok {
+		return t.TermCharEnabled()
+	}
+	return false, nil
+}
+
+// SetTermCharEnabled turns stopping reads at the termination character on or
+// off, where the transport supports it
+func (r *Rigol) SetTermCharEnabled(on bool) error {
+	if t, ok := r.Transport.(termCharTransport); ok {
+		return t.SetTermCharEnabled(on)
+	}
+	return nil
+}
+
+// readBinary reads a binary response with the termination character turned
+// off, as the data may well contain 0x0a bytes. It is restored afterwards.
+func (r *Rigol) readBinary(bytes uint32) ([]byte, error) {
+	on, err := r.TermCharEnabled()
+	if err != nil {
+		return nil, err
+	}
+	if on {
+		if err := r.SetTermCharEnabled(false); err != nil {
+			return nil, err
+		}
+		defer r.SetTermCharEnabled(true)
+	}
+	return r.Read(bytes)
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TriggerConfig is the edge trigger and acquisition setup used by Arm
+type TriggerConfig struct {
+	Source      Source  // edge trigger source, see SetTriggerSource
+	Slope       string  // POS, NEG or RFAL
+	Level       float64 // trigger level in volts
+	MemoryDepth int64   // points, see SetMemoryDepth
+	Timebase    float64 // seconds per division
+}
+
+// DefaultTriggerConfig is the setup Trigger uses, rising edge on CH1 at 3v. The
+// memory depth is one of the steps allowed with CH1 and one LA pod on.
+func DefaultTriggerConfig() TriggerConfig {
+	return TriggerConfig{
+		Source:      SourceCH(1),
+		Slope:       "POS",
+		Level:       3,
+		MemoryDepth: 60000,
+		Timebase:    0.0002,
+	}
+}
+
+// Arm sets up the edge trigger and acquisition from cfg and starts a single
+// shot capture. Channels and the LA are left as they are. The source is checked
+// as SetTriggerSource does.
+func (r *Rigol) Arm(cfg TriggerConfig) error {
+	if err := r.checkTriggerSource(cfg.Source); err != nil {
+		return err
+	}
+	setup := []string{
+		":TRIG:MODE EDGE", // trigger mode to edge
+		cmd(":TRIG:EDG:SOUR", cfg.Source),
+		cmd(":TRIG:EDG:SLOP", cfg.Slope),
+		cmd(":TRIG:EDG:LEV", cfg.Level),
+		cmd(":ACQ:MDEP", cfg.MemoryDepth),
+		cmd(":TIM:MAIN:SCAL", cfg.Timebase),
+		":ACQ:TYPE HRES", // High resolution mode
+		":SING",          // single shot wait for trigger
+	}
+	r.runState = ""
+	r.forcedStop = false
+	return r.WriteAll(setup)
+}
+
+// triggerStatus returns :TRIG:STAT?, TD, WAIT, RUN, AUTO or STOP, noting the
+// last state before the scope stopped for DidTrigger
+func (r *Rigol) triggerStatus() (string, error) {
+	state, err := r.Query(":TRIG:STAT?")
+	if err != nil {
+		return "", err
+	}
+	if state != "STOP" {
+		r.runState = state
+	}
+	return state, nil
+}
+
+// DidTrigger reports whether the acquisition triggered, rather than being
+// swept by AUTO without one or stopped by this package. While running it's
+// whether the scope is in TD. Once stopped it's true if the last :TRIG:STAT?
+// poll before the stop, from WaitForCapture, WaitForTrigger or a fetch
+// stopping the scope, was TD. Otherwise it's false if a fetch stopped the scope
+// (see WithStopBeforeRead), and in single and normal sweep, which only capture
+// on a trigger, true. In AUTO sweep it's false, so a trigger between polls is
+// missed. A stop from the front panel or another connection in single or
+// normal sweep can't be told from a trigger.
+func (r *Rigol) DidTrigger() (bool, error) {
+	state, err := r.triggerStatus()
+	if err != nil {
+		return false, err
+	}
+	if state != "STOP" {
+		return state == "TD", nil
+	}
+	if r.runState == "TD" {
+		return true, nil
+	}
+	if r.forcedStop {
+		return false, nil
+	}
+	sweep, err := r.Query(":TRIG:SWE?")
+	if err != nil {
+		return false, err
+	}
+	if sweep != "AUTO" {
+		return true, nil
+	}
+	if r.runState == "" {
+		return false, errors.New("trigger state not seen since the acquisition started")
+	}
+	return false, nil
+}
+
+// Trigger only sources, for the EXT input on models that have one
+const (
+	SourceExt  Source = "EXT"  // the EXT input
+	SourceExt5 Source = "EXT5" // the EXT input attenuated by 5
+)
+
+// SetTriggerSource sets the edge trigger source to an analog or LA channel, or
+// SourceExt or SourceExt5. Only the 2 channel models have the EXT input, the
+// others return ErrUnsupported for it.
+func (r *Rigol) SetTriggerSource(source Source) error {
+	if err := r.checkTriggerSource(source); err != nil {
+		return err
+	}
+	return r.Write(cmd(":TRIG:EDG:SOUR", source))
+}
+
+// checkTriggerSource returns an error unless source can be the edge trigger
+// source on this model
+func (r *Rigol) checkTriggerSource(source Source) error {
+	switch source {
+	case SourceExt, SourceExt5:
+		caps, err := r.Capabilities()
+		if err != nil {
+			return err
+		}
+		if !caps.HasExtTrig {
+			return fmt.Errorf("external trigger on %s: %w", caps.Model, ErrUnsupported)
+		}
+	case SourceMath:
+		return errors.New("math can't be a trigger source")
+	default:
+		return r.checkSource(source)
+	}
+	return nil
+}
+
+// TriggerCoupling filters the trigger source before the trigger circuit
+type TriggerCoupling string
+
+const (
+	TriggerCouplingAC  TriggerCoupling = "AC"  // blocks the DC component
+	TriggerCouplingDC  TriggerCoupling = "DC"  // passes both AC and DC
+	TriggerCouplingLFR TriggerCoupling = "LFR" // rejects below 75kHz
+	TriggerCouplingHFR TriggerCoupling = "HFR" // rejects above 75kHz
+)
+
+// SetTriggerCoupling sets the trigger coupling, which applies to analog
+// channel trigger sources only
+func (r *Rigol) SetTriggerCoupling(c TriggerCoupling) error {
+	switch c {
+	case TriggerCouplingAC, TriggerCouplingDC, TriggerCouplingLFR, TriggerCouplingHFR:
+	default:
+		return fmt.Errorf("invalid trigger coupling %q", c)
+	}
+	return r.Write(cmd(":TRIG:COUP", c))
+}
+
+// SetTriggerNoiseReject turns trigger noise rejection on or off. It stops a
+// noisy signal retriggering around the level, at the cost of sensitivity.
+func (r *Rigol) SetTriggerNoiseReject(on bool) error {
+	return r.Write(cmd(":TRIG:NREJ", on))
+}
+
+// SetTriggerLevelPercent sets the edge trigger level pct (0-100) of the way up
+// analog channel ch's display range, from its current scale and offset. The
+// screen is 8 divisions high and centred on -offset.
+func (r *Rigol) SetTriggerLevelPercent(ch int, pct float64) error {
+	if err := checkChannel(ch); err != nil {
+		return err
+	}
+	if pct < 0 || pct > 100 {
+		return fmt.Errorf("invalid trigger level %g%%, expected 0-100", pct)
+	}
+	scale, err := r.QueryFloat(fmt.Sprintf(":CHAN%d:SCAL?", ch))
+	if err != nil {
+		return err
+	}
+	offset, err := r.QueryFloat(fmt.Sprintf(":CHAN%d:OFFS?", ch))
+	if err != nil {
+		return err
+	}
+	level := -offset + scale*8*(pct/100-0.5)
+	return r.Write(cmd(":TRIG:EDG:LEV", level))
+}
+
+// PatternState is what a channel must be doing for a pattern trigger
+type PatternState string
+
+const (
+	PatternHigh    PatternState = "H"
+	PatternLow     PatternState = "L"
+	PatternRising  PatternState = "R"
+	PatternFalling PatternState = "F"
+	PatternIgnore  PatternState = "X"
+)
+
+// SetPatternTrigger triggers when the LA channels match pattern, keyed by
+// channel number (0-15 for D0-D15). Channels not in pattern, and the analog
+// channels, are ignored. At most one channel can be Rising or Falling, the
+// rest are levels judged against the pod thresholds, see SetPatternLevel.
+func (r *Rigol) SetPatternTrigger(pattern map[int]PatternState) error {
+	// CH1-CH4 then D0-D15
+	states := make([]string, 20)
+	for i := range states {
+		states[i] = string(PatternIgnore)
+	}
+	edges := 0
+	for ch, state := range pattern {
+		if ch < 0 || ch > 15 {
+			return fmt.Errorf("invalid LA channel D%d in pattern", ch)
+		}
+		switch state {
+		case PatternRising, PatternFalling:
+			edges++
+		case PatternHigh, PatternLow, PatternIgnore:
+		default:
+			return fmt.Errorf("invalid pattern state %q for D%d", state, ch)
+		}
+		states[4+ch] = string(state)
+	}
+	if edges > 1 {
+		return fmt.Errorf("pattern has %d edges, at most 1 is allowed", edges)
+	}
+	setup := []string{
+		":TRIG:MODE PATT",
+		cmd(":TRIG:PATT:PATT", strings.Join(states, ",")),
+	}
+	return r.WriteAll(setup)
+}
+
+// SetPatternLevel sets the level source is judged High or Low against in a
+// pattern trigger. For an analog channel that's :TRIG:PATT:LEV. An LA channel
+// has no level of its own, it's compared with its pod's threshold, so this
+// sets the threshold (as ConfigureLA does) for all 8 channels of the pod.
+func (r *Rigol) SetPatternLevel(source Source, level float64) error {
+	kind, n, err := r.patternSource(source)
+	if err != nil {
+		return err
+	}
+	if kind == "D" {
+		if level < -15 || level > 15 {
+			return fmt.Errorf("POD%d threshold %gv out of range -15v to 15v", n/8+1, level)
+		}
+		return r.Write(cmd(fmt.Sprintf(":LA:POD%d:THR", n/8+1), level))
+	}
+	return r.Write(cmd(":TRIG:PATT:LEV", source, level))
+}
+
+// PatternLevel reads back the level source is judged against in a pattern
+// trigger, see SetPatternLevel
+func (r *Rigol) PatternLevel(source Source) (float64, error) {
+	kind, n, err := r.patternSource(source)
+	if err != nil {
+		return 0, err
+	}
+	if kind == "D" {
+		return r.QueryFloat(fmt.Sprintf(":LA:POD%d:THR?", n/8+1))
+	}
+	return r.QueryFloat(cmd(":TRIG:PATT:LEV?", source))
+}
+
+// patternSource parses source for SetPatternLevel and PatternLevel, checking
+// the model has it and that it's an analog or LA channel
+func (r *Rigol) patternSource(source Source) (string, int, error) {
+	if err := r.checkSource(source); err != nil {
+		return "", 0, err
+	}
+	kind, n, _ := source.parse()
+	if kind == "MATH" {
+		return "", 0, errors.New("math can't be a trigger source")
+	}
+	return kind, n, nil
+}
+
+// PulseWhen is the pulse width condition for a pulse trigger. The P forms
+// trigger on positive pulses and the N forms on negative ones.
+type PulseWhen string
+
+const (
+	PulseGreaterP PulseWhen = "PGR"  // positive pulse wider than lower
+	PulseLessP    PulseWhen = "PLES" // positive pulse narrower than upper
+	PulseWithinP  PulseWhen = "PGL"  // positive pulse between lower and upper
+	PulseGreaterN PulseWhen = "NGR"
+	PulseLessN    PulseWhen = "NLES"
+	PulseWithinN  PulseWhen = "NGL"
+)
+
+// SetPulseTrigger triggers on pulses on source whose width meets when. Only
+// the limits when uses are sent, lower for the greater than forms, upper for
+// less than and both for within. Widths can be 8ns to 10s.
+func (r *Rigol) SetPulseTrigger(source Source, when PulseWhen, lower, upper time.Duration) error {
+	if err := r.checkSource(source); err != nil {
+		return err
+	}
+	if source == SourceMath {
+		return errors.New("math can't be a trigger source")
+	}
+	check := func(name string, d time.Duration) error {
+		if d < 8*time.Nanosecond || d > 10*time.Second {
+			return fmt.Errorf("pulse %s width %v out of range 8ns to 10s", name, d)
+		}
+		return nil
+	}
+
+	setup := []string{
+		":TRIG:MODE PULS",
+		cmd(":TRIG:PULS:SOUR", source),
+		cmd(":TRIG:PULS:WHEN", when),
+	}
+	switch when {
+	case PulseGreaterP, PulseGreaterN:
+		if err := check("lower", lower); err != nil {
+			return err
+		}
+		setup = append(setup, cmd(":TRIG:PULS:LWID", lower.Seconds()))
+	case PulseLessP, PulseLessN:
+		if err := check("upper", upper); err != nil {
+			return err
+		}
+		setup = append(setup, cmd(":TRIG:PULS:UWID", upper.Seconds()))
+	case PulseWithinP, PulseWithinN:
+		if err := check("lower", lower); err != nil {
+			return err
+		}
+		if err := check("upper", upper); err != nil {
+			return err
+		}
+		if lower >= upper {
+			return fmt.Errorf("pulse lower width %v not below upper width %v", lower, upper)
+		}
+		// upper first, the scope won't take a lower width above the current upper
+		setup = append(setup,
+			cmd(":TRIG:PULS:UWID", upper.Seconds()),
+			cmd(":TRIG:PULS:LWID", lower.Seconds()))
+	default:
+		return fmt.Errorf("invalid pulse condition %q", when)
+	}
+	return r.WriteAll(setup)
+}
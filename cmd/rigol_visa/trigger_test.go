@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWaitForTrigger(t *testing.T) {
+	tests := []struct {
+		sweep  string
+		states []string
+		want   error
+	}{
+		// TD lasts a few ms, so a 50ms poll usually goes straight from WAIT to STOP
+		{"SING", []string{"WAIT", "WAIT", "STOP"}, nil},
+		{"NORM", []string{"WAIT", "STOP"}, nil},
+		{"AUTO", []string{"AUTO", "TD", "STOP"}, nil},
+		{"AUTO", []string{"AUTO", "AUTO", "STOP"}, ErrNoTrigger},
+	}
+	for _, tt := range tests {
+		f := &fakeScope{handle: replies(map[string][]string{
+			":TRIG:SWE?":  {tt.sweep},
+			":TRIG:STAT?": tt.states,
+		})}
+		r := &Rigol{Transport: f}
+		if err := r.WaitForTrigger(context.Background()); err != tt.want {
+			t.Errorf("%s sweep through %v: got %v, want %v", tt.sweep, tt.states, err, tt.want)
+		}
+	}
+}
+
+func TestDidTrigger(t *testing.T) {
+	tests := []struct {
+		sweep  string
+		states []string // polls before DidTrigger, which makes the last
+		want   bool
+	}{
+		{"SING", []string{"WAIT", "STOP"}, true},
+		{"NORM", []string{"STOP"}, true},
+		{"AUTO", []string{"AUTO", "TD", "STOP"}, true},
+		{"AUTO", []string{"AUTO", "STOP"}, false},
+		{"SING", []string{"WAIT"}, false},
+	}
+	for _, tt := range tests {
+		f := &fakeScope{handle: replies(map[string][]string{
+			":TRIG:SWE?":  {tt.sweep},
+			":TRIG:STAT?": tt.states,
+		})}
+		r := &Rigol{Transport: f}
+		for range tt.states[1:] {
+			if _, err := r.triggerStatus(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := r.DidTrigger()
+		if err != nil {
+			t.Errorf("%s sweep through %v: %v", tt.sweep, tt.states, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s sweep through %v: got %v, want %v", tt.sweep, tt.states, got, tt.want)
+		}
+	}
+}
+
+func TestDidTriggerForcedStop(t *testing.T) {
+	tests := []struct {
+		states []string // seen by stop, then DidTrigger
+		want   bool
+	}{
+		{[]string{"WAIT", "STOP"}, false}, // stopped before a trigger
+		{[]string{"TD", "STOP"}, true},    // stopped as it triggered
+		{[]string{"STOP"}, true},          // already stopped by a trigger
+	}
+	for _, tt := range tests {
+		f := &fakeScope{handle: replies(map[string][]string{
+			"*IDN?":       {"RIGOL TECHNOLOGIES,DS1054Z,DS1ZA000000000,00.04.04.SP3"},
+			":TRIG:SWE?":  {"SING"},
+			":TRIG:STAT?": tt.states,
+		})}
+		r := &Rigol{Transport: f}
+		if err := r.Arm(DefaultTriggerConfig()); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.stop(); err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.DidTrigger()
+		if err != nil || got != tt.want {
+			t.Errorf("stopped in %v: got %v, %v, want %v", tt.states, got, err, tt.want)
+		}
+	}
+}
+
+func TestWaitForTriggerForcedStop(t *testing.T) {
+	// a fetch with WithStopBeforeRead stopped the scope before it triggered
+	f := &fakeScope{handle: replies(map[string][]string{
+		"*IDN?":       {"RIGOL TECHNOLOGIES,DS1054Z,DS1ZA000000000,00.04.04.SP3"},
+		":TRIG:SWE?":  {"SING"},
+		":TRIG:STAT?": {"WAIT", "STOP"},
+	})}
+	r := &Rigol{Transport: f}
+	if err := r.Arm(DefaultTriggerConfig()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WaitForTrigger(context.Background()); err != ErrNoTrigger {
+		t.Errorf("got %v, want ErrNoTrigger", err)
+	}
+}
+
+func TestPatternLevel(t *testing.T) {
+	f := &fakeScope{handle: replies(map[string][]string{
+		"*IDN?":                 {"RIGOL TECHNOLOGIES,MSO1104Z-S,DS1ZC000000000,00.04.04.SP3"},
+		":TRIG:PATT:LEV? CHAN2": {"1.500000e+00"},
+		":LA:POD2:THR?":         {"3.300000e+00"},
+	})}
+	r := &Rigol{Transport: f}
+	if err := r.SetPatternLevel(SourceCH(2), 1.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetPatternLevel(SourceDigital(9), 3.3); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"*IDN?", ":TRIG:PATT:LEV CHAN2,1.5", ":LA:POD2:THR 3.3"}
+	if strings.Join(f.written, ";") != strings.Join(want, ";") {
+		t.Errorf("sent %q, want %q", f.written, want)
+	}
+	if v, err := r.PatternLevel(SourceCH(2)); err != nil || v != 1.5 {
+		t.Errorf("CH2 level = %g, %v, want 1.5", v, err)
+	}
+	if v, err := r.PatternLevel(SourceDigital(9)); err != nil || v != 3.3 {
+		t.Errorf("D9 level = %g, %v, want 3.3", v, err)
+	}
+	if err := r.SetPatternLevel(SourceMath, 1); err == nil {
+		t.Error("expected an error for MATH")
+	}
+}
+
+func TestArmChecksSource(t *testing.T) {
+	for _, tt := range []struct {
+		idn    string
+		source Source
+		ok     bool
+	}{
+		{"RIGOL TECHNOLOGIES,DS1202Z-E,DS1ZE000000000,00.06.02", SourceExt, true},
+		{"RIGOL TECHNOLOGIES,DS1054Z,DS1ZA000000000,00.04.04.SP3", SourceExt, false},
+		{"RIGOL TECHNOLOGIES,DS1054Z,DS1ZA000000000,00.04.04.SP3", SourceMath, false},
+	} {
+		f := &fakeScope{handle: replies(map[string][]string{"*IDN?": {tt.idn}})}
+		r := &Rigol{Transport: f}
+		cfg := DefaultTriggerConfig()
+		cfg.Source = tt.source
+		if err := r.Arm(cfg); (err == nil) != tt.ok {
+			t.Errorf("%s on %s: got %v", tt.source, tt.idn, err)
+		}
+		if !tt.ok && len(f.written) > 1 {
+			t.Errorf("%s on %s: sent %q after rejecting it", tt.source, tt.idn, f.written)
+		}
+	}
+}
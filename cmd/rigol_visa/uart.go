@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// UARTDecode recovers 8N1 bytes sent LSB first on pin (0-7) of LA data, idle
+// high, samplesPerBit samples to each bit. Each bit is sampled in its middle,
+// timed from the falling edge of its start bit. A frame without a high stop
+// bit is dropped as a framing error.
+func UARTDecode(data []byte, pin int, samplesPerBit float64) ([]byte, error) {
+	frames, err := uartFrames(data, pin, samplesPerBit)
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	for _, f := range frames {
+		out = append(out, f.b)
+	}
+	return out, nil
+}
+
+// uartFrame is a byte found by uartFrames and the sample its start bit began at
+type uartFrame struct {
+	start int
+	b     byte
+}
+
+// uartFrames is UARTDecode, keeping where each byte started
+func uartFrames(data []byte, pin int, samplesPerBit float64) ([]uartFrame, error) {
+	if pin < 0 || pin > 7 {
+		return nil, fmt.Errorf("invalid pin %d, expected 0-7", pin)
+	}
+	if samplesPerBit < 3 {
+		return nil, fmt.Errorf("%.1f samples per bit is too few to decode reliably", samplesPerBit)
+	}
+	level := func(i int) byte { return (data[i] >> pin) & 1 }
+	at := func(start int, bits float64) int { return start + int(math.Round(samplesPerBit*bits)) }
+
+	var out []uartFrame
+	for i := 1; i < len(data); i++ {
+		if level(i-1) != 1 || level(i) != 0 {
+			continue
+		}
+		stop := at(i, 9.5)
+		if stop >= len(data) {
+			break
+		}
+		if level(at(i, 0.5)) != 0 {
+			continue // glitch, not a start bit
+		}
+		var b byte
+		for bit := 0; bit < 8; bit++ {
+			b |= level(at(i, 1.5+float64(bit))) << bit
+		}
+		if level(stop) == 1 {
+			out = append(out, uartFrame{start: i, b: b})
+		}
+		i = stop
+	}
+	return out, nil
+}
+
+// UARTDecoder is a Decoder for 8N1 UART data on one LA pin, see UARTDecode.
+// Each byte is an event of type "UART" timed at the start of its start bit.
+type UARTDecoder struct {
+	Pin  int // 0-7
+	Baud int
+}
+
+func (d UARTDecoder) Decode(capture *LogicCapture) ([]DecodeEvent, error) {
+	if d.Baud <= 0 {
+		return nil, fmt.Errorf("invalid baud rate %d", d.Baud)
+	}
+	if capture.Interval <= 0 {
+		return nil, errors.New("capture has no sample interval")
+	}
+	frames, err := uartFrames(capture.Samples, d.Pin, 1/(float64(d.Baud)*capture.Interval))
+	if err != nil {
+		return nil, err
+	}
+	events := make([]DecodeEvent, len(frames))
+	for i, f := range frames {
+		events[i] = DecodeEvent{
+			Time:  capture.Start + float64(f.start)*capture.Interval,
+			Type:  "UART",
+			Value: fmt.Sprintf("0x%02X", f.b),
+		}
+	}
+	return events, nil
+}
+
+// CaptureUART arms a single capture with cfg, waits for it, reads POD1 and
+// decodes the UART on pin (D0-D7) at baud. The LA must already be set up, see
+// ConfigureLA.
+func (r *Rigol) CaptureUART(cfg TriggerConfig, pin int, baud int) ([]byte, error) {
+	if baud <= 0 {
+		return nil, fmt.Errorf("invalid baud rate %d", baud)
+	}
+	if err := r.Arm(cfg); err != nil {
+		return nil, err
+	}
+	if _, err := r.WaitForCapture(); err != nil {
+		return nil, err
+	}
+	data, p, err := r.Acquire(SourceDigital(0))
+	if err != nil {
+		return nil, err
+	}
+	if p.Xincrement <= 0 {
+		return nil, errors.New("preamble has no sample interval")
+	}
+	return UARTDecode(data, pin, 1/(float64(baud)*p.Xincrement))
+}
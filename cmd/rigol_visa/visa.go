@@ -0,0 +1,155 @@
+//go:build !novisa
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	vi "github.com/jpoirier/visa"
+)
+
+const noVISAHint = "check a VISA implementation such as NI-VISA is installed, or use InitTCP or InitVXI11 which don't need one"
+
+// visaTransport talks to the scope through the NI-VISA library
+type visaTransport struct {
+	Instr           vi.Object
+	ResourceManager vi.Session
+
+	sharedRM bool // ResourceManager belongs to the caller, don't close it
+}
+
+type visaOptions struct {
+	rm       vi.Session
+	sharedRM bool
+}
+
+// WithResourceManager opens the session through an existing resource manager
+// instead of the default one. It is left open by Close.
+func WithResourceManager(rm vi.Session) Option {
+	return func(o *options) {
+		o.visa.rm = rm
+		o.visa.sharedRM = true
+	}
+}
+
+func openVISA(connStr string, o options) (*visaTransport, error) {
+	t := &visaTransport{sharedRM: o.visa.sharedRM}
+	if t.sharedRM {
+		t.ResourceManager = o.visa.rm
+	} else {
+		rm, status := vi.OpenDefaultRM()
+		if status < vi.SUCCESS {
+			return nil, errors.New("could not open a session to the VISA Resource Manager, " + noVISAHint)
+		}
+		t.ResourceManager = rm
+	}
+
+	instr, status := t.ResourceManager.Open(connStr, vi.NULL, vi.NULL)
+	if status < vi.SUCCESS {
+		t.closeRM()
+		// A resource manager without a backend behind it (e.g. a VISA shared
+		// library shim with no implementation installed) opens fine, then
+		// fails every Open with one of these
+		if status == vi.ERROR_LIBRARY_NFOUND || status == vi.ERROR_RSRC_NFOUND {
+			return nil, fmt.Errorf("could not open %s (VISA error %x), %s", connStr, uint32(status), noVISAHint)
+		}
+		return nil, fmt.Errorf("an error occurred opening the session to %s", connStr)
+	}
+	t.Instr = instr
+
+	if err := t.configure(o); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *visaTransport) configure(o options) error {
+	if o.timeout > 0 {
+		ms := uint32(o.timeout / time.Millisecond)
+		if status := t.Instr.SetAttribute(vi.ATTR_TMO_VALUE, ms); status < vi.SUCCESS {
+			return fmt.Errorf("could not set the VISA timeout: %v", status)
+		}
+	}
+	if o.termCharSet {
+		if status := t.Instr.SetAttribute(vi.ATTR_TERMCHAR, uint32(o.termChar)); status < vi.SUCCESS {
+			return fmt.Errorf("could not set the VISA termination character: %v", status)
+		}
+		if err := t.SetTermCharEnabled(true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *visaTransport) Close() error {
+	t.Instr.Close()
+	return t.closeRM()
+}
+
+func (t *visaTransport) closeRM() error {
+	if t.sharedRM {
+		return nil
+	}
+	if status := t.ResourceManager.Close(); status < vi.SUCCESS {
+		return fmt.Errorf("error closing the VISA session: %v", status)
+	}
+	return nil
+}
+
+func (t *visaTransport) Write(b []byte) error {
+	_, status := t.Instr.Write(b, uint32(len(b)))
+	if status < vi.SUCCESS {
+		return fmt.Errorf("error writing to the device: %v", status)
+	}
+	return nil
+}
+
+// Read returns up to bytes of the response. VISA stops at the end of the
+// message (or the term char), or with VI_SUCCESS_MAX_CNT once bytes have
+// arrived, in which case the rest is returned by the following Reads.
+func (t *visaTransport) Read(bytes uint32) ([]byte, error) {
+	b, n, status := t.Instr.Read(bytes)
+	if status < vi.SUCCESS {
+		return nil, fmt.Errorf("read failed with error code %x", status)
+	}
+	return b[:n], nil
+}
+
+// ReadAll reads a chunk at a time until VISA reports the end of the message
+// (or the term char), however long the response is
+func (t *visaTransport) ReadAll() ([]byte, error) {
+	var data []byte
+	for {
+		b, n, status := t.Instr.Read(readAllChunk)
+		if status < vi.SUCCESS {
+			return nil, fmt.Errorf("read failed with error code %x", status)
+		}
+		data = append(data, b[:n]...)
+		if status != vi.SUCCESS_MAX_CNT {
+			return data, nil
+		}
+	}
+}
+
+func (t *visaTransport) TermCharEnabled() (bool, error) {
+	var on uint16 // ViBoolean
+	if status := t.Instr.GetAttribute(vi.ATTR_TERMCHAR_EN, unsafe.Pointer(&on)); status < vi.SUCCESS {
+		return false, fmt.Errorf("could not read the VISA termination character setting: %v", status)
+	}
+	return on != uint16(vi.FALSE), nil
+}
+
+func (t *visaTransport) SetTermCharEnabled(on bool) error {
+	state := uint32(vi.FALSE)
+	if on {
+		state = uint32(vi.TRUE)
+	}
+	if status := t.Instr.SetAttribute(vi.ATTR_TERMCHAR_EN, state); status < vi.SUCCESS {
+		return fmt.Errorf("could not set the VISA termination character: %v", status)
+	}
+	return nil
+}
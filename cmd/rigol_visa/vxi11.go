@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"time"
+)
+
+// VXI-11 is ONC RPC over TCP, see the VXI-11 spec and RFC 5531/4506 for the
+// message and XDR encodings. Only the handful of calls needed for SCPI are here.
+
+const (
+	portmapProg    = 100000
+	portmapVers    = 2
+	portmapGetPort = 3
+
+	vxiCoreProg    = 0x0607af
+	vxiCoreVers    = 1
+	vxiCreateLink  = 10
+	vxiDeviceWrite = 11
+	vxiDeviceRead  = 12
+	vxiDestroyLink = 23
+
+	vxiFlagEnd   = 0x08 // device_write: last chunk of the message
+	vxiReasonEnd = 0x04 // device_read: end of message reached
+	vxiReasonChr = 0x02 // device_read: term char reached
+)
+
+// VXI11Transport talks to the scope over LXI VXI-11, the protocol behind
+// VISA's TCPIP::<host>::INSTR resources, without needing VISA installed
+type VXI11Transport struct {
+	Timeout time.Duration // io timeout given to the scope for each call
+
+	rpc     *rpcClient
+	lid     uint32 // link id from create_link
+	maxRecv uint32 // largest device_write chunk the scope accepts
+}
+
+// DialVXI11 asks the portmapper on host for the VXI-11 core port and opens a
+// link to the scope's inst0 device
+func DialVXI11(host string) (*VXI11Transport, error) {
+	t := &VXI11Transport{Timeout: 10 * time.Second}
+
+	pm, err := dialRPC(net.JoinHostPort(host, "111"), t.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	args := xdrEncoder{}
+	args.uint32(vxiCoreProg)
+	args.uint32(vxiCoreVers)
+	args.uint32(6) // tcp
+	args.uint32(0)
+	res, err := pm.call(portmapProg, portmapVers, portmapGetPort, args, t.Timeout)
+	pm.conn.Close()
+	if err != nil {
+		return nil, fmt.Errorf("portmapper lookup failed: %v", err)
+	}
+	port := res.uint32()
+	if res.err != nil || port == 0 {
+		return nil, errors.New("scope does not offer VXI-11")
+	}
+
+	t.rpc, err = dialRPC(net.JoinHostPort(host, fmt.Sprint(port)), t.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	args = xdrEncoder{}
+	args.uint32(0) // client id
+	args.uint32(0) // don't lock the device
+	args.uint32(0) // lock timeout
+	args.string("inst0")
+	res, err = t.rpc.call(vxiCoreProg, vxiCoreVers, vxiCreateLink, args, t.Timeout)
+	if err == nil {
+		err = vxiError("create_link", res.uint32())
+	}
+	if err != nil {
+		t.rpc.conn.Close()
+		return nil, err
+	}
+	t.lid = res.uint32()
+	res.uint32() // abort port, unused
+	t.maxRecv = res.uint32()
+	if res.err != nil {
+		t.rpc.conn.Close()
+		return nil, res.err
+	}
+	return t, nil
+}
+
+func (t *VXI11Transport) Close() error {
+	args := xdrEncoder{}
+	args.uint32(t.lid)
+	res, err := t.rpc.call(vxiCoreProg, vxiCoreVers, vxiDestroyLink, args, t.Timeout)
+	if err == nil {
+		err = vxiError("destroy_link", res.uint32())
+	}
+	t.rpc.conn.Close()
+	return err
+}
+
+func (t *VXI11Transport) Write(b []byte) error {
+	for {
+		chunk := b
+		flags := uint32(vxiFlagEnd)
+		if t.maxRecv > 0 && uint32(len(chunk)) > t.maxRecv {
+			chunk = chunk[:t.maxRecv]
+			flags = 0
+		}
+		args := xdrEncoder{}
+		args.uint32(t.lid)
+		args.uint32(uint32(t.Timeout / time.Millisecond)) // io timeout
+		args.uint32(0)                                    // lock timeout
+		args.uint32(flags)
+		args.opaque(chunk)
+		res, err := t.rpc.call(vxiCoreProg, vxiCoreVers, vxiDeviceWrite, args, t.Timeout)
+		if err == nil {
+			err = vxiError("device_write", res.uint32())
+		}
+		if err != nil {
+			return fmt.Errorf("error writing to the device: %v", err)
+		}
+		b = b[len(chunk):]
+		if len(b) == 0 {
+			return nil
+		}
+	}
+}
+
+// Read returns once the scope signals the end of the response or n bytes have
+// been received
+func (t *VXI11Transport) Read(n uint32) ([]byte, error) {
+	return t.read(n, n)
+}
+
+// ReadAll reads until the scope signals the end of the response, however long
+func (t *VXI11Transport) ReadAll() ([]byte, error) {
+	return t.read(math.MaxUint32, readAllChunk)
+}
+
+// read asks for at most chunk bytes per device_read
+func (t *VXI11Transport) read(n, chunk uint32) ([]byte, error) {
+	var data []byte
+	for uint32(len(data)) < n {
+		size := n - uint32(len(data))
+		if size > chunk {
+			size = chunk
+		}
+		args := xdrEncoder{}
+		args.uint32(t.lid)
+		args.uint32(size)
+		args.uint32(uint32(t.Timeout / time.Millisecond)) // io timeout
+		args.uint32(0)                                    // lock timeout
+		args.uint32(0)                                    // flags, no term char
+		args.uint32(0)                                    // term char
+		res, err := t.rpc.call(vxiCoreProg, vxiCoreVers, vxiDeviceRead, args, t.Timeout)
+		if err == nil {
+			err = vxiError("device_read", res.uint32())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read failed: %v", err)
+		}
+		reason := res.uint32()
+		data = append(data, res.opaque()...)
+		if res.err != nil {
+			return nil, fmt.Errorf("read failed: %v", res.err)
+		}
+		if reason&(vxiReasonEnd|vxiReasonChr) != 0 {
+			break
+		}
+	}
+	return data, nil
+}
+
+func vxiError(op string, code uint32) error {
+	if code == 0 {
+		return nil
+	}
+	return fmt.Errorf("VXI-11 %s failed with error %d", op, code)
+}
+
+// rpcClient makes ONC RPC calls over a TCP connection using record marking
+type rpcClient struct {
+	conn net.Conn
+	xid  uint32
+}
+
+func dialRPC(addr string, timeout time.Duration) (*rpcClient, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to %s: %v", addr, err)
+	}
+	return &rpcClient{conn: conn}, nil
+}
+
+// call sends an RPC and returns a decoder positioned at the start of the
+// results. The deadline allows for the scope's own io timeout plus some slack.
+func (c *rpcClient) call(prog, vers, proc uint32, args xdrEncoder, timeout time.Duration) (*xdrDecoder, error) {
+	c.xid++
+	msg := xdrEncoder{}
+	msg.uint32(0) // record mark, filled in below
+	msg.uint32(c.xid)
+	msg.uint32(0) // call
+	msg.uint32(2) // rpc version
+	msg.uint32(prog)
+	msg.uint32(vers)
+	msg.uint32(proc)
+	msg.uint32(0) // auth none
+	msg.uint32(0)
+	msg.uint32(0) // verifier none
+	msg.uint32(0)
+	msg = append(msg, args...)
+	binary.BigEndian.PutUint32(msg, 0x80000000|uint32(len(msg)-4))
+
+	c.conn.SetDeadline(time.Now().Add(timeout + 5*time.Second))
+	if _, err := c.conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var reply []byte
+	for {
+		var mark [4]byte
+		if _, err := io.ReadFull(c.conn, mark[:]); err != nil {
+			return nil, err
+		}
+		h := binary.BigEndian.Uint32(mark[:])
+		frag := make([]byte, h&0x7fffffff)
+		if _, err := io.ReadFull(c.conn, frag); err != nil {
+			return nil, err
+		}
+		reply = append(reply, frag...)
+		if h&0x80000000 != 0 {
+			break
+		}
+	}
+
+	d := &xdrDecoder{b: reply}
+	if xid := d.uint32(); xid != c.xid {
+		return nil, fmt.Errorf("rpc reply for call %d, expected %d", xid, c.xid)
+	}
+	if d.uint32() != 1 {
+		return nil, errors.New("rpc reply expected")
+	}
+	if d.uint32() != 0 {
+		return nil, errors.New("rpc call denied")
+	}
+	d.uint32() // verifier flavour
+	d.opaque()
+	if stat := d.uint32(); stat != 0 {
+		return nil, fmt.Errorf("rpc call not accepted, status %d", stat)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return d, nil
+}
+
+type xdrEncoder []byte
+
+func (e *xdrEncoder) uint32(v uint32) {
+	*e = binary.BigEndian.AppendUint32(*e, v)
+}
+
+func (e *xdrEncoder) opaque(b []byte) {
+	e.uint32(uint32(len(b)))
+	*e = append(*e, b...)
+	for len(*e)%4 != 0 {
+		*e = append(*e, 0)
+	}
+}
+
+func (e *xdrEncoder) string(s string) {
+	e.opaque([]byte(s))
+}
+
+// xdrDecoder reads fields in order, recording the first error rather than
+// making the caller check every field
+type xdrDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *xdrDecoder) uint32() uint32 {
+	if len(d.b) < 4 {
+		d.err = errors.New("short rpc reply")
+		return 0
+	}
+	v := binary.BigEndian.Uint32(d.b)
+	d.b = d.b[4:]
+	return v
+}
+
+func (d *xdrDecoder) opaque() []byte {
+	n := d.uint32()
+	padded := (n + 3) &^ 3
+	if d.err != nil || uint32(len(d.b)) < padded {
+		d.err = errors.New("short rpc reply")
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[padded:]
+	return v
+}
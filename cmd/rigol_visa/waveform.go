@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WaveformFormat is the :WAV:FORM data format
+type WaveformFormat string
+
+const (
+	FormatByte WaveformFormat = "BYTE" // 1 byte per sample
+	FormatWord WaveformFormat = "WORD" // 2 bytes per sample
+)
+
+// SetWaveformFormat picks the data format used by later waveform fetches
+func (r *Rigol) SetWaveformFormat(f WaveformFormat) error {
+	switch f {
+	case FormatByte, FormatWord:
+	default:
+		return fmt.Errorf("unsupported waveform format %q", f)
+	}
+	if err := r.Write(cmd(":WAV:FORM", f)); err != nil {
+		return err
+	}
+	r.format = f
+	return nil
+}
+
+// waveformFormat is the format last set, BYTE if it hasn't been
+func (r *Rigol) waveformFormat() WaveformFormat {
+	if r.format == "" {
+		return FormatByte
+	}
+	return r.format
+}
+
+// BytesPerSample is the size of each point in waveform data for the current
+// format, 1 for BYTE and 2 for WORD
+func (r *Rigol) BytesPerSample() int {
+	if r.waveformFormat() == FormatWord {
+		return 2
+	}
+	return 1
+}
+
+// WaveformMode is the :WAV:MODE, which points of memory a fetch returns
+type WaveformMode string
+
+const (
+	// ModeNormal reads the 1200 points on screen, whether running or not
+	ModeNormal WaveformMode = "NORM"
+	// ModeMax reads the points on screen while running and all of memory
+	// once stopped, so a quick grab works without stopping the scope
+	ModeMax WaveformMode = "MAX"
+	// ModeRaw reads all of memory, and needs the scope stopped
+	ModeRaw WaveformMode = "RAW"
+)
+
+// SetWaveformMode picks the mode used by later fetches that go through
+// selectSource, RAW if it's never set. FetchScreenWaveform always uses NORM.
+func (r *Rigol) SetWaveformMode(m WaveformMode) error {
+	switch m {
+	case ModeNormal, ModeMax, ModeRaw:
+	default:
+		return fmt.Errorf("unsupported waveform mode %q", m)
+	}
+	if err := r.Write(cmd(":WAV:MODE", m)); err != nil {
+		return err
+	}
+	r.mode = m
+	return nil
+}
+
+// waveformMode is the mode last set, RAW if it hasn't been
+func (r *Rigol) waveformMode() WaveformMode {
+	if r.mode == "" {
+		return ModeRaw
+	}
+	return r.mode
+}
+
+// UnpackSamples turns waveform data in the current format into one value per
+// sample. WORD samples are little-endian unless set otherwise by WithByteOrder,
+// and WORD data of an odd length, which has lost a byte, is an error.
+func (r *Rigol) UnpackSamples(data []byte) ([]uint16, error) {
+	if r.waveformFormat() != FormatWord {
+		samples := make([]uint16, len(data))
+		for i, b := range data {
+			samples[i] = uint16(b)
+		}
+		return samples, nil
+	}
+	if len(data)%2 != 0 {
+		return nil, fmt.Errorf("WORD waveform data has an odd length of %d bytes", len(data))
+	}
+	order := r.byteOrder
+	if order == nil {
+		order = binary.LittleEndian
+	}
+	samples := make([]uint16, len(data)/2)
+	for i := range samples {
+		samples[i] = order.Uint16(data[2*i:])
+	}
+	return samples, nil
+}
+
+// ParseTMCHeader reads the header at the start of a TMC block response
+// (#<n><length><data>), returning the header and the data length it gives.
+// The header is 2+n bytes long, n being the number of digits in the length
+// field, so it's worked out from each response rather than assumed.
+func ParseTMCHeader(d []byte) ([]byte, int, error) {
+	if len(d) < 2 || d[0] != '#' || d[1] < '1' || d[1] > '9' {
+		return nil, 0, errors.New("response is not a TMC block")
+	}
+	headerLen := 2 + int(d[1]-'0')
+	if len(d) < headerLen {
+		return nil, 0, errors.New("TMC block header truncated")
+	}
+	length, err := strconv.Atoi(string(d[2:headerLen]))
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid TMC block length %q", d[2:headerLen])
+	}
+	return d[:headerLen], length, nil
+}
+
+// splitTMCBlock separates a TMC block response into its header and data
+func splitTMCBlock(d []byte) ([]byte, []byte, error) {
+	header, length, err := ParseTMCHeader(d)
+	if err != nil {
+		return nil, nil, err
+	}
+	headerLen := len(header)
+	if len(d) < headerLen+length {
+		return nil, nil, fmt.Errorf("TMC block truncated, got %d of %d bytes", len(d)-headerLen, length)
+	}
+	return header, d[headerLen : headerLen+length], nil
+}
+
+// selectSource sets up reads from source in the current mode (RAW unless set
+// by SetWaveformMode), after checking it is displayed. The scope happily
+// returns stale or zero data for a source that is turned off.
+func (r *Rigol) selectSource(source Source) error {
+	if r.stopBeforeRead && r.waveformMode() == ModeRaw {
+		if err := r.stop(); err != nil {
+			return err
+		}
+	}
+	if err := r.checkSourceDisplayed(source); err != nil {
+		return err
+	}
+	setup := []string{
+		cmd(":WAV:SOUR", source),             // waveform source
+		cmd(":WAV:MODE", r.waveformMode()),   // see SetWaveformMode
+		cmd(":WAV:FORM", r.waveformFormat()), // data format, see SetWaveformFormat
+	}
+	if err := r.WriteAll(setup); err != nil {
+		return err
+	}
+	if r.waveformMode() != ModeRaw {
+		return nil
+	}
+
+	// some models in the family don't do RAW, and silently ignore the command
+	mode, err := r.Query(":WAV:MODE?")
+	if err != nil {
+		return err
+	}
+	if mode != "RAW" {
+		r.logf("warning: RAW waveform mode not supported, falling back to NORM (screen data only)")
+		return r.Write(":WAV:MODE NORM")
+	}
+	return nil
+}
+
+// stop sends :STOP and waits up to a second for the scope to report it has
+// stopped. Stopping a running acquisition is noted for DidTrigger.
+func (r *Rigol) stop() error {
+	state, err := r.triggerStatus()
+	if err != nil {
+		return err
+	}
+	if state == "STOP" {
+		return nil
+	}
+	r.forcedStop = true
+	if err := r.Write(":STOP"); err != nil {
+		return err
+	}
+	for i := 0; i < 10; i++ {
+		state, err := r.triggerStatus()
+		if err != nil {
+			return err
+		}
+		if state == "STOP" {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return errors.New("scope did not stop")
+}
+
+// checkSourceDisplayed returns an error if the channel, LA pod or math channel
+// for source is turned off, or source isn't valid
+func (r *Rigol) checkSourceDisplayed(source Source) error {
+	if err := r.checkSource(source); err != nil {
+		return err
+	}
+	kind, n, _ := source.parse()
+	var query string
+	switch kind {
+	case "MATH":
+		query = ":MATH:DISP?"
+	case "CHAN":
+		query = fmt.Sprintf(":CHAN%d:DISP?", n)
+	case "D":
+		query = fmt.Sprintf(":LA:POD%d:DISP?", n/8+1)
+	}
+	disp, err := r.Query(query)
+	if err != nil {
+		return err
+	}
+	if disp != "1" {
+		return fmt.Errorf("source %s is not displayed, turn it on before fetching", source)
+	}
+	return nil
+}
+
+// maxPointsPerRead is the most points :WAV:DATA? returns in one go in RAW mode
+const maxPointsPerRead = 125000
+
+// fetchWindow reads points start to stop (1 based, inclusive) of the current
+// waveform source, returning the TMC header and the data
+func (r *Rigol) fetchWindow(start, stop int64) ([]byte, []byte, error) {
+	d, err := r.readWindow(start, stop)
+	if err != nil {
+		return nil, nil, err
+	}
+	return splitTMCBlock(d)
+}
+
+// readWindow is fetchWindow without the TMC block parsing
+func (r *Rigol) readWindow(start, stop int64) ([]byte, error) {
+	if start < 1 || stop < start {
+		return nil, fmt.Errorf("invalid window %d-%d", start, stop)
+	}
+	if n := stop - start + 1; n > maxPointsPerRead {
+		return nil, fmt.Errorf("window %d-%d is %d points, at most %d can be read at once", start, stop, n, maxPointsPerRead)
+	}
+	window := []string{
+		cmd(":WAV:STAR", start),
+		cmd(":WAV:STOP", stop),
+	}
+	if r.compound {
+		// one message, so one round trip rather than three
+		window = append(window, ":WAV:DATA?")
+		if err := r.Write(strings.Join(window, ";")); err != nil {
+			return nil, err
+		}
+	} else {
+		// the query is sent on its own so WithErrorCheck can't interrupt it
+		if err := r.WriteAll(window); err != nil {
+			return nil, err
+		}
+		if err := r.Write(":WAV:DATA?"); err != nil {
+			return nil, err
+		}
+	}
+	// room for the header and trailing newline as well as the points
+	return r.readBinary(uint32((stop-start+1)*int64(r.BytesPerSample())) + 12)
+}
+
+// FetchWaveformWindow reads points start to stop (1 based, inclusive) of
+// source, returning the TMC header and the data. At most 125000 points can be
+// read in one window, use FetchFullWaveform for more.
+func (r *Rigol) FetchWaveformWindow(source Source, start, stop int64) ([]byte, []byte, error) {
+	if err := r.selectSource(source); err != nil {
+		return nil, nil, err
+	}
+	return r.fetchWindow(start, stop)
+}
+
+// FetchFullWaveform reads every point in memory for source, 125k points at a
+// time. The number of points comes from the preamble, so the scope must be
+// stopped for it to reflect the whole acquisition. ctx is checked between
+// chunks, so a long fetch can be abandoned.
+func (r *Rigol) FetchFullWaveform(ctx context.Context, source Source) ([]byte, error) {
+	if err := r.selectSource(source); err != nil {
+		return nil, err
+	}
+	p, err := r.readPreamble()
+	if err != nil {
+		return nil, err
+	}
+	return r.fetchRange(ctx, 1, p.Points)
+}
+
+// fetchRange reads points first to last (1 based, inclusive) of the current
+// source, in as many windows as it takes. Some firmware returns fewer points
+// than asked for, so each window starts after the last point actually read
+// and is no bigger than the last one returned.
+func (r *Rigol) fetchRange(ctx context.Context, first, last int64) ([]byte, error) {
+	var data []byte
+	total := (last - first + 1) * int64(r.BytesPerSample())
+	if r.maxFetchBytes > 0 && total > r.maxFetchBytes {
+		return nil, fmt.Errorf("fetching points %d-%d would read %d bytes, more than the limit of %d", first, last, total, r.maxFetchBytes)
+	}
+	window := int64(maxPointsPerRead)
+	for start := first; start <= last; {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		stop := start + window - 1
+		if stop > last {
+			stop = last
+		}
+		_, chunk, err := r.fetchWindow(start, stop)
+		if err != nil {
+			return nil, fmt.Errorf("fetching points %d-%d: %v", start, stop, err)
+		}
+		got := int64(len(chunk) / r.BytesPerSample())
+		if got == 0 {
+			return nil, fmt.Errorf("fetching points %d-%d: no data returned", start, stop)
+		}
+		if want := stop - start + 1; got < want {
+			r.logf("warning: asked for %d points, got %d, reducing the window size", want, got)
+			window = got
+		} else if got > want {
+			got = want
+			chunk = chunk[:got*int64(r.BytesPerSample())]
+		}
+		data = append(data, chunk...)
+		start += got
+		if r.onProgress != nil {
+			r.onProgress(int64(len(data)), total)
+		}
+	}
+	return data, nil
+}
+
+// FetchWaveformPercent reads the part of memory for source between startPct
+// and stopPct (0-100) of the way through, e.g. 45 and 55 for the middle 10%
+func (r *Rigol) FetchWaveformPercent(source Source, startPct, stopPct float64) ([]byte, error) {
+	if startPct < 0 || stopPct > 100 || startPct >= stopPct {
+		return nil, fmt.Errorf("invalid window %g%%-%g%%", startPct, stopPct)
+	}
+	if err := r.selectSource(source); err != nil {
+		return nil, err
+	}
+	p, err := r.readPreamble()
+	if err != nil {
+		return nil, err
+	}
+	first := int64(math.Floor(float64(p.Points)*startPct/100)) + 1
+	last := int64(math.Ceil(float64(p.Points) * stopPct / 100))
+	if last < first {
+		last = first
+	}
+	return r.fetchRange(context.Background(), first, last)
+}
+
+// FetchWaveformRaw is FetchWaveformData without any processing of the
+// response, the TMC header and trailing newline are left in place. Useful for
+// debugging protocol problems.
+func (r *Rigol) FetchWaveformRaw(source Source) ([]byte, error) {
+	if err := r.selectSource(source); err != nil {
+		return nil, err
+	}
+	return r.readWindow(1, maxPointsPerRead)
+}
+
+// FetchScreenWaveform reads just the points on screen (NORM mode) for source,
+// along with their preamble. It's a single quick read, and the scope doesn't
+// need to be stopped, so it suits live previews.
+func (r *Rigol) FetchScreenWaveform(source Source) ([]byte, *Preamble, error) {
+	if err := r.checkSourceDisplayed(source); err != nil {
+		return nil, nil, err
+	}
+	setup := []string{
+		cmd(":WAV:SOUR", source),
+		":WAV:MODE NORM",
+		cmd(":WAV:FORM", r.waveformFormat()),
+	}
+	if err := r.WriteAll(setup); err != nil {
+		return nil, nil, err
+	}
+	p, err := r.sourcePreamble(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, data, err := r.fetchWindow(1, p.Points)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, p, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestUnpackSamples(t *testing.T) {
+	data := []byte{0x01, 0x02, 0xff, 0x00}
+	tests := []struct {
+		name string
+		r    *Rigol
+		want []uint16
+	}{
+		{"byte", &Rigol{}, []uint16{0x01, 0x02, 0xff, 0x00}},
+		{"word default", &Rigol{format: FormatWord}, []uint16{0x0201, 0x00ff}},
+		{"word little-endian", &Rigol{format: FormatWord, byteOrder: binary.LittleEndian}, []uint16{0x0201, 0x00ff}},
+		{"word big-endian", &Rigol{format: FormatWord, byteOrder: binary.BigEndian}, []uint16{0x0102, 0xff00}},
+	}
+	for _, tt := range tests {
+		got, err := tt.r.UnpackSamples(data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#04x, want %#04x", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := (&Rigol{format: FormatWord}).UnpackSamples(data[:3]); err == nil {
+		t.Error("expected an error for odd length WORD data")
+	}
+}
+
+// rawScope is a fakeScope handler for RAW waveform reads of depth points from
+// every analog channel. Like some firmware it resets :WAV:STAR and :WAV:STOP
+// to the screen's 1-1200 whenever :WAV:SOUR changes, and it returns at most
+// maxPointsPerRead points per :WAV:DATA?.
+func rawScope(depth int64) func(string) []byte {
+	start, stop := int64(1), int64(1200)
+	return func(msg string) []byte {
+		root, arg, _ := strings.Cut(msg, " ")
+		n, _ := strconv.ParseInt(arg, 10, 64)
+		switch {
+		case root == ":WAV:SOUR":
+			start, stop = 1, 1200
+		case root == ":WAV:STAR":
+			start = n
+		case root == ":WAV:STOP":
+			stop = n
+		case root == "*IDN?":
+			return []byte("RIGOL TECHNOLOGIES,DS1054Z,DS1ZA000000000,00.04.04.SP3\n")
+		case root == ":WAV:MODE?":
+			return []byte("RAW\n")
+		case root == ":WAV:PRE?":
+			return []byte(fmt.Sprintf("0,2,%d,1,1.000000e-08,-6.000000e-04,0,4.000000e-02,0,127\n", depth))
+		case root == ":WAV:DATA?":
+			if stop > depth {
+				stop = depth
+			}
+			if stop-start+1 > maxPointsPerRead {
+				stop = start + maxPointsPerRead - 1
+			}
+			data := make([]byte, stop-start+1)
+			return []byte(fmt.Sprintf("#9%09d%s\n", len(data), data))
+		case strings.HasSuffix(root, ":DISP?"):
+			return []byte("1\n")
+		case strings.HasSuffix(root, ":UNIT?"):
+			return []byte("VOLT\n")
+		case strings.HasSuffix(root, "?"):
+			return []byte("0\n")
+		}
+		return nil
+	}
+}
+
+func TestFetchChannelsSameLength(t *testing.T) {
+	const depth = 300000 // three reads
+	f := &fakeScope{handle: rawScope(depth)}
+	r := &Rigol{Transport: f}
+	for _, ch := range []int{1, 2} {
+		f.written = nil
+		data, p, err := r.FetchWaveformWithPreamble(SourceCH(ch))
+		if err != nil {
+			t.Fatalf("CH%d: %v", ch, err)
+		}
+		if p.Points != depth || len(data) != depth {
+			t.Errorf("CH%d: got %d points and %d bytes, want %d", ch, p.Points, len(data), depth)
+		}
+		// the window is set for each read, not carried over between sources
+		for _, msg := range f.written {
+			if msg == ":WAV:PRE?" {
+				break
+			}
+			if strings.HasPrefix(msg, ":WAV:STAR") || strings.HasPrefix(msg, ":WAV:STOP") {
+				t.Errorf("CH%d: %s sent while selecting the source", ch, msg)
+			}
+		}
+	}
+}
+
+func TestStopBeforeReadOnlyRaw(t *testing.T) {
+	for _, mode := range []WaveformMode{ModeRaw, ModeNormal, ModeMax} {
+		state, raw := "RUN", rawScope(1200)
+		f := &fakeScope{handle: func(msg string) []byte {
+			switch msg {
+			case ":STOP":
+				state = "STOP"
+			case ":TRIG:STAT?":
+				return []byte(state + "\n")
+			}
+			return raw(msg)
+		}}
+		r := &Rigol{Transport: f, mode: mode, stopBeforeRead: true}
+		if err := r.selectSource(SourceCH(1)); err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		stopped := false
+		for _, msg := range f.written {
+			stopped = stopped || msg == ":STOP"
+		}
+		if stopped != (mode == ModeRaw) {
+			t.Errorf("%s: sent :STOP %v, want %v", mode, stopped, mode == ModeRaw)
+		}
+	}
+}
+
+func TestFetchPathsFillUnitAndInverted(t *testing.T) {
+	source, raw := "", rawScope(1200)
+	f := &fakeScope{handle: func(msg string) []byte {
+		root, arg, _ := strings.Cut(msg, " ")
+		switch root {
+		case ":WAV:SOUR":
+			source = arg
+		case ":WAV:SOUR?":
+			return []byte(source + "\n")
+		case ":CHAN2:INV?":
+			return []byte("1\n")
+		case ":CHAN2:UNIT?":
+			return []byte("AMP\n")
+		}
+		return raw(msg)
+	}}
+	r := &Rigol{Transport: f}
+	check := func(name string, p *Preamble) {
+		if p.Unit != UnitAmp || !p.Inverted {
+			t.Errorf("%s: got unit %q and inverted %v, want AMP and true", name, p.Unit, p.Inverted)
+		}
+	}
+
+	_, p, err := r.FetchScreenWaveform(SourceCH(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("FetchScreenWaveform", p)
+	if _, _, err := r.FetchWaveformData(SourceCH(2)); err != nil {
+		t.Fatal(err)
+	}
+	p, err = r.FetchPreamble()
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("FetchPreamble", p)
+}